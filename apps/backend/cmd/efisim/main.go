@@ -0,0 +1,67 @@
+// Command efisim executa o simulador local da API Pix da EFI.
+//
+// Ele gera uma CA nova a cada execução e grava em -out a CA (ca.pem) e o par
+// de cliente (client.pem, client-key.pem). Para apontar o backend para o
+// simulador:
+//
+//	EFI_BASE_URL=https://127.0.0.1:8443 \
+//	EFI_CA_FILE=./efisim-certs/ca.pem \
+//	EFI_CLIENT_CA_FILE_SANDBOX=./efisim-certs/ca.pem \
+//	EFI_CLIENT_CERT_FILE=./efisim-certs/client.pem \
+//	EFI_CLIENT_KEY_FILE=./efisim-certs/client-key.pem \
+//	go run . --server
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"pix_cli/simulator"
+)
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:8443", "endereço de escuta")
+	out := flag.String("out", "./efisim-certs", "diretório onde gravar CA e certificado de cliente")
+	clientID := flag.String("client-id", "Client_Id_simulador", "client_id aceito no /oauth/token")
+	clientSecret := flag.String("client-secret", "Client_Secret_simulador", "client_secret aceito no /oauth/token")
+	tokenLifetime := flag.Duration("token-lifetime", time.Hour, "validade dos tokens emitidos")
+	failCount := flag.Int("fail-count", 0, "quantidade de requisições de webhook que devem falhar ao iniciar")
+	failStatus := flag.Int("fail-status", 503, "status HTTP das falhas injetadas com -fail-count")
+	failRetryAfter := flag.Duration("fail-retry-after", 0, "Retry-After enviado nas falhas injetadas (ex: 1s)")
+	flag.Parse()
+
+	pki, err := simulator.NewPKI()
+	if err != nil {
+		log.Fatalf("❌ Erro ao gerar PKI: %v", err)
+	}
+
+	if err := pki.WriteFiles(*out); err != nil {
+		log.Fatalf("❌ Erro ao gravar certificados: %v", err)
+	}
+	log.Printf("🔐 CA e certificado de cliente gravados em %s", *out)
+
+	server := simulator.NewServer(pki, simulator.Config{
+		ClientID:      *clientID,
+		ClientSecret:  *clientSecret,
+		TokenLifetime: *tokenLifetime,
+	})
+
+	if *failCount > 0 {
+		server.FailNext(*failCount, *failStatus, *failRetryAfter)
+		log.Printf("💥 As próximas %d requisições de webhook vão falhar com %d", *failCount, *failStatus)
+	}
+
+	if err := server.Start(*addr); err != nil {
+		log.Fatalf("❌ Erro ao iniciar simulador: %v", err)
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt)
+	<-stop
+
+	log.Println("👋 Encerrando simulador")
+	server.Close()
+}
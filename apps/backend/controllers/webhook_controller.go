@@ -1,91 +1,105 @@
 package controllers
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
 
 	"pix_cli/models"
 	"pix_cli/services"
 )
 
 type WebhookController struct {
-	efiService *services.EFIService
+	efiService services.EFIClient
 }
 
-func NewWebhookController(efiService *services.EFIService) *WebhookController {
+func NewWebhookController(efiService services.EFIClient) *WebhookController {
 	return &WebhookController{
 		efiService: efiService,
 	}
 }
 
-func (c *WebhookController) GetEFIService() *services.EFIService {
+func (c *WebhookController) GetEFIService() services.EFIClient {
 	return c.efiService
 }
 
-func (c *WebhookController) ConfigWebhook(webhookType models.WebhookType, webhookURL string) error {
+func (c *WebhookController) ConfigWebhook(webhookType models.WebhookType, webhookURL string) (*models.WebhookResponse, error) {
+	return c.ConfigWebhookContext(context.Background(), webhookType, webhookURL)
+}
+
+func (c *WebhookController) ConfigWebhookContext(ctx context.Context, webhookType models.WebhookType, webhookURL string) (*models.WebhookResponse, error) {
+	return c.ConfigWebhookWithOptionsContext(ctx, webhookType, webhookURL, models.WebhookOptions{})
+}
+
+// ConfigWebhookWithOptionsContext configura o webhook com as opções
+// informadas, como a dispensa da validação mTLS do receptor
+func (c *WebhookController) ConfigWebhookWithOptionsContext(ctx context.Context, webhookType models.WebhookType, webhookURL string, opts models.WebhookOptions) (*models.WebhookResponse, error) {
 	if webhookURL == "" {
-		return fmt.Errorf("URL do webhook é obrigatória")
+		return nil, fmt.Errorf("URL do webhook é obrigatória")
 	}
 
 	if c.efiService == nil {
-		return fmt.Errorf("serviço EFI não está disponível - configure as credenciais")
+		return nil, fmt.Errorf("serviço EFI não está disponível - configure as credenciais")
 	}
 
-	log.Printf("Configurando webhook %s com URL: %s", webhookType, webhookURL)
+	slog.InfoContext(ctx, "configurando webhook", "type", webhookType, "url", services.RedactURL(webhookURL), "skip_mtls", opts.SkipMTLS)
 
-	response, err := c.efiService.ConfigWebhook(webhookType, webhookURL)
+	response, err := c.efiService.ExecuteWebhookCommandContext(ctx, &models.WebhookCommand{
+		Type:     webhookType,
+		Action:   "config",
+		URL:      webhookURL,
+		Params:   map[string]string{},
+		Body:     map[string]interface{}{"webhookUrl": webhookURL},
+		SkipMTLS: opts.SkipMTLS,
+	})
 	if err != nil {
-		return fmt.Errorf("erro ao configurar webhook: %v", err)
+		return nil, fmt.Errorf("erro ao configurar webhook: %w", err)
 	}
 
-	fmt.Printf("✅ Webhook %s configurado com sucesso!\n", webhookType)
-	fmt.Printf("📋 Resposta: %+v\n", response.Data)
-	return nil
+	return response, nil
+}
+
+func (c *WebhookController) DeleteWebhook(webhookType models.WebhookType) (*models.WebhookResponse, error) {
+	return c.DeleteWebhookContext(context.Background(), webhookType)
 }
 
-func (c *WebhookController) DeleteWebhook(webhookType models.WebhookType) error {
+func (c *WebhookController) DeleteWebhookContext(ctx context.Context, webhookType models.WebhookType) (*models.WebhookResponse, error) {
 	if c.efiService == nil {
-		return fmt.Errorf("serviço EFI não está disponível - configure as credenciais")
+		return nil, fmt.Errorf("serviço EFI não está disponível - configure as credenciais")
 	}
 
-	log.Printf("Removendo webhook %s", webhookType)
+	slog.InfoContext(ctx, "removendo webhook", "type", webhookType)
 
-	response, err := c.efiService.DeleteWebhook(webhookType)
+	response, err := c.efiService.DeleteWebhookContext(ctx, webhookType)
 	if err != nil {
-		return fmt.Errorf("erro ao remover webhook: %v", err)
+		return nil, fmt.Errorf("erro ao remover webhook: %w", err)
 	}
 
-	fmt.Printf("✅ Webhook %s removido com sucesso!\n", webhookType)
-	fmt.Printf("📋 Resposta: %+v\n", response.Data)
-	return nil
+	return response, nil
+}
+
+func (c *WebhookController) ListWebhook(webhookType models.WebhookType) (*models.WebhookResponse, error) {
+	return c.ListWebhookContext(context.Background(), webhookType)
 }
 
-func (c *WebhookController) ListWebhook(webhookType models.WebhookType) error {
+// ListWebhookContext retorna o webhook configurado, ou nil sem erro quando a
+// EFI informa que não há webhook do tipo
+func (c *WebhookController) ListWebhookContext(ctx context.Context, webhookType models.WebhookType) (*models.WebhookResponse, error) {
 	if c.efiService == nil {
-		return fmt.Errorf("serviço EFI não está disponível - configure as credenciais")
+		return nil, fmt.Errorf("serviço EFI não está disponível - configure as credenciais")
 	}
 
-	log.Printf("Listando webhooks %s", webhookType)
+	slog.DebugContext(ctx, "listando webhooks", "type", webhookType)
 
-	response, err := c.efiService.ListWebhook(webhookType)
+	response, err := c.efiService.ListWebhookContext(ctx, webhookType)
 	if err != nil {
-		if response != nil && response.Code == 404 {
-			fmt.Printf("📋 Nenhum webhook %s configurado\n", webhookType)
-			return nil
+		if services.IsNotFound(err) {
+			return nil, nil
 		}
-		return fmt.Errorf("erro ao listar webhooks: %v", err)
-	}
-
-	if response.Code == 200 {
-		fmt.Printf("📋 Webhook %s configurado:\n", webhookType)
-		fmt.Printf("📊 URL: %v\n", response.Data["webhookUrl"])
-		fmt.Printf("📊 Criação: %v\n", response.Data["criacao"])
-	} else {
-		fmt.Printf("📋 Webhooks %s configurados:\n", webhookType)
-		fmt.Printf("📊 Resposta: %+v\n", response.Data)
+		return nil, fmt.Errorf("erro ao listar webhooks: %w", err)
 	}
 
-	return nil
+	return response, nil
 }
 
 func (c *WebhookController) ValidateWebhookType(webhookType string) (models.WebhookType, error) {
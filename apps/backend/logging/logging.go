@@ -0,0 +1,107 @@
+// Package logging configura o logger estruturado (log/slog) do backend e
+// carrega o request ID de cada requisição HTTP pelo context.Context.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+type contextKey struct{}
+
+// Setup configura o logger padrão a partir de LOG_LEVEL (debug, info, warn,
+// error) e LOG_FORMAT (text ou json). Mensagens de log.Printf de dependências
+// passam a sair pelo mesmo handler.
+func Setup() error {
+	level, err := ParseLevel(os.Getenv("LOG_LEVEL"))
+	if err != nil {
+		return err
+	}
+
+	handler, err := NewHandler(os.Stderr, os.Getenv("LOG_FORMAT"), level)
+	if err != nil {
+		return err
+	}
+
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// ParseLevel converte o nível configurado; vazio equivale a info
+func ParseLevel(value string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "debug":
+		return slog.LevelDebug, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return slog.LevelInfo, fmt.Errorf("LOG_LEVEL inválido: %s (use debug, info, warn ou error)", value)
+}
+
+// NewHandler cria o handler no formato text (padrão) ou json
+func NewHandler(w io.Writer, format string, level slog.Level) (slog.Handler, error) {
+	opts := &slog.HandlerOptions{Level: level}
+
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "", "text":
+		return contextHandler{slog.NewTextHandler(w, opts)}, nil
+	case "json":
+		return contextHandler{slog.NewJSONHandler(w, opts)}, nil
+	}
+	return nil, fmt.Errorf("LOG_FORMAT inválido: %s (use text ou json)", format)
+}
+
+// NewRequestID gera um identificador aleatório para a requisição
+func NewRequestID() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		slog.Error("erro ao gerar request ID", "error", err)
+		return "unknown"
+	}
+	return hex.EncodeToString(buf)
+}
+
+// WithRequestID associa o request ID ao contexto
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, contextKey{}, requestID)
+}
+
+// RequestID retorna o request ID do contexto, ou vazio se não houver
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	requestID, _ := ctx.Value(contextKey{}).(string)
+	return requestID
+}
+
+// contextHandler acrescenta o request ID do contexto a cada registro, para
+// que slog.InfoContext(ctx, ...) em qualquer camada saia correlacionado
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if requestID := RequestID(ctx); requestID != "" {
+		r.AddAttrs(slog.String("request_id", requestID))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
@@ -3,49 +3,176 @@ package main
 import (
 	"bufio"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"strings"
 
 	"pix_cli/controllers"
+	"pix_cli/logging"
 	"pix_cli/services"
 )
 
+// storePath é o arquivo do armazenamento local (usuários, auditoria,
+// histórico de webhooks e eventos recebidos)
+const storePath = "./data/pix.db"
+
 func main() {
-	log.SetFlags(log.LstdFlags | log.Lshortfile)
+	if err := logging.Setup(); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		os.Exit(1)
+	}
 
 	os.Setenv("GODEBUG", "x509negativeserial=1")
 
+	if len(os.Args) > 1 && os.Args[1] == "--generate-secret-key" {
+		generateSecretKey()
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "--migrate-secrets" {
+		migrateSecrets()
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "--create-admin" {
+		createAdmin()
+		return
+	}
+
 	if len(os.Args) > 1 && os.Args[1] == "--server" {
-		log.Println("🚀 Iniciando servidor HTTP...")
+		slog.Info("iniciando servidor HTTP")
+
+		registry := services.NewServiceRegistry()
+		if len(os.Args) > 2 && os.Args[2] == "--fake-efi" {
+			slog.Info("usando cliente EFI em memória (--fake-efi)")
+			registry = services.NewServiceRegistryWithFactory(func(env string) (services.EFIClient, error) {
+				return services.NewFakeEFIService(), nil
+			})
+		}
+
+		if _, err := registry.Get("sandbox"); err != nil {
+			slog.Warn("não foi possível inicializar serviço EFI; configure as credenciais e o certificado", "env", "sandbox", "error", err)
+		}
+
+		monitor, err := services.NewCertificateMonitorFromEnv(services.KnownEnvironments)
+		if err != nil {
+			fatal("erro ao configurar monitor de certificados", err)
+		}
 
-		credentials, err := services.LoadCredentials()
+		store, err := services.OpenStore(storePath)
 		if err != nil {
-			log.Printf("⚠️  Aviso: Não foi possível carregar credenciais: %v", err)
-			log.Println("📝 Configure as variáveis de ambiente para usar a API EFI")
-		} else {
-			log.Printf("✅ Credenciais carregadas com sucesso: %+v", credentials)
+			fatal("erro ao abrir armazenamento local", err)
 		}
+		defer store.Close()
 
-		var efiService *services.EFIService
-		if credentials != nil {
-			efiService, err = services.NewEFIService(credentials)
-			if err != nil {
-				log.Printf("⚠️  Aviso: Não foi possível inicializar serviço EFI: %v", err)
-			} else {
-				log.Printf("✅ Serviço EFI inicializado com sucesso")
-			}
+		sessionTTL, err := services.LoadSessionTTL()
+		if err != nil {
+			fatal("erro ao configurar sessões", err)
 		}
 
-		controller := controllers.NewWebhookController(efiService)
+		cors, err := loadCORSPolicy()
+		if err != nil {
+			fatal("erro ao configurar CORS", err)
+		}
+
+		receiver, err := loadReceiverConfig()
+		if err != nil {
+			fatal("erro ao configurar receptor de webhooks", err)
+		}
+
+		eventRetention, err := services.LoadEventRetention()
+		if err != nil {
+			fatal("erro ao configurar retenção de eventos", err)
+		}
 
-		server := NewServer(controller, 8081)
+		server := NewServer(registry, monitor, store, services.NewSessionManager(sessionTTL), cors, receiver, eventRetention, 8081)
 		if err := server.Start(); err != nil {
-			log.Fatal("Erro ao iniciar servidor:", err)
+			fatal("erro ao iniciar servidor", err)
 		}
 	}
 }
 
+// fatal registra o erro e encerra o processo
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+// createAdmin cria um usuário administrador local. Uso:
+//
+//	go run . --create-admin <usuário>
+//
+// A senha é lida de PIX_ADMIN_PASSWORD ou, se ausente, da entrada padrão.
+func createAdmin() {
+	if len(os.Args) < 3 {
+		fmt.Println("Uso: --create-admin <usuário>")
+		os.Exit(2)
+	}
+	username := os.Args[2]
+
+	store, err := services.OpenStore(storePath)
+	if err != nil {
+		fatal("erro ao abrir armazenamento local", err)
+	}
+	defer store.Close()
+	users := services.NewUserStore(store)
+
+	password := os.Getenv("PIX_ADMIN_PASSWORD")
+	if password == "" {
+		fmt.Printf("Senha para %s: ", username)
+		line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		password = strings.TrimRight(line, "\r\n")
+	}
+
+	if _, err := users.Create(username, password, services.RoleAdmin); err != nil {
+		fatal("erro ao criar administrador", err)
+	}
+
+	fmt.Printf("✅ Administrador %s criado\n", username)
+}
+
+// generateSecretKey imprime uma nova chave para PIX_SECRET_KEY
+func generateSecretKey() {
+	key, err := services.GenerateSecretKey()
+	if err != nil {
+		fatal("erro ao gerar chave", err)
+	}
+	fmt.Println(key)
+}
+
+// migrateSecrets criptografa as credenciais, certificados e o armazenamento
+// local ainda gravados em texto puro usando a chave de PIX_SECRET_KEY ou
+// PIX_SECRET_KEY_FILE
+func migrateSecrets() {
+	paths, err := services.SecretFilePaths()
+	if err != nil {
+		fatal("erro ao listar arquivos", err)
+	}
+
+	migrated, err := services.MigrateSecretFiles(paths)
+	if err != nil {
+		fatal("erro ao migrar arquivos", err)
+	}
+
+	fmt.Printf("✅ %d arquivo(s) criptografado(s), %d verificado(s)\n", len(migrated), len(paths))
+
+	if _, err := os.Stat(storePath); err != nil {
+		return
+	}
+
+	// Reescreve o armazenamento com cada registro criptografado
+	store, err := services.OpenStore(storePath)
+	if err != nil {
+		fatal("erro ao abrir armazenamento local", err)
+	}
+	defer store.Close()
+
+	if err := store.Compact(); err != nil {
+		fatal("erro ao criptografar armazenamento local", err)
+	}
+	fmt.Printf("✅ Armazenamento %s criptografado\n", storePath)
+}
+
 func showMenu(controller *controllers.WebhookController) {
 	reader := bufio.NewReader(os.Stdin)
 
@@ -107,11 +234,14 @@ func configWebhook(controller *controllers.WebhookController, webhookType string
 
 	fmt.Printf("⏳ Configurando webhook %s com URL: %s\n", webhookType, url)
 
-	if err := controller.ConfigWebhook(webhookTypeEnum, url); err != nil {
+	response, err := controller.ConfigWebhook(webhookTypeEnum, url)
+	if err != nil {
 		fmt.Printf("❌ Erro ao configurar webhook: %s\n", err.Error())
-	} else {
-		fmt.Printf("✅ Webhook %s configurado com sucesso!\n", webhookType)
+		return
 	}
+
+	fmt.Printf("✅ Webhook %s configurado com sucesso!\n", webhookType)
+	fmt.Printf("📋 Resposta: %+v\n", services.RedactValue(response.Data))
 }
 
 func listWebhook(controller *controllers.WebhookController, webhookType string) {
@@ -123,9 +253,20 @@ func listWebhook(controller *controllers.WebhookController, webhookType string)
 		return
 	}
 
-	if err := controller.ListWebhook(webhookTypeEnum); err != nil {
+	response, err := controller.ListWebhook(webhookTypeEnum)
+	if err != nil {
 		fmt.Printf("❌ Erro ao listar webhooks: %s\n", err.Error())
+		return
+	}
+
+	if response == nil {
+		fmt.Printf("📋 Nenhum webhook %s configurado\n", webhookType)
+		return
 	}
+
+	fmt.Printf("📋 Webhook %s configurado:\n", webhookType)
+	fmt.Printf("📊 URL: %v\n", services.RedactValue(response.Data["webhookUrl"]))
+	fmt.Printf("📊 Criação: %v\n", response.Data["criacao"])
 }
 
 func deleteWebhook(controller *controllers.WebhookController, webhookType string, reader *bufio.Reader) {
@@ -146,9 +287,12 @@ func deleteWebhook(controller *controllers.WebhookController, webhookType string
 		return
 	}
 
-	if err := controller.DeleteWebhook(webhookTypeEnum); err != nil {
+	response, err := controller.DeleteWebhook(webhookTypeEnum)
+	if err != nil {
 		fmt.Printf("❌ Erro ao remover webhook: %s\n", err.Error())
-	} else {
-		fmt.Printf("✅ Webhook %s removido com sucesso!\n", webhookType)
+		return
 	}
+
+	fmt.Printf("✅ Webhook %s removido com sucesso!\n", webhookType)
+	fmt.Printf("📋 Resposta: %+v\n", services.RedactValue(response.Data))
 }
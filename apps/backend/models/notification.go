@@ -0,0 +1,387 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Payloads enviados pela EFI aos webhooks. A decodificação é estrita: campos
+// desconhecidos, tipos errados, valores fora do formato da API Pix
+// (\d{1,10}\.\d{2}) e datas fora do RFC 3339 são rejeitados. Objetos que o
+// receptor apenas repassa (devedor, recebedor, gnExtras...) ficam em
+// json.RawMessage.
+
+var (
+	amountPattern     = regexp.MustCompile(`^\d{1,10}\.\d{2}$`)
+	endToEndIDPattern = regexp.MustCompile(`^[Ee][0-9]{8}[0-9]{12}[a-zA-Z0-9]{11}$`)
+	returnIDPattern   = regexp.MustCompile(`^[Dd][0-9]{8}[0-9]{12}[a-zA-Z0-9]{11}$`)
+	txidPattern       = regexp.MustCompile(`^[a-zA-Z0-9]{26,35}$`)
+	recIDPattern      = regexp.MustCompile(`^R[RN][0-9]{8}[0-9]{8}[a-zA-Z0-9]{11}$`)
+)
+
+// Status das devoluções de um Pix
+const (
+	ReturnStatusProcessing  = "EM_PROCESSAMENTO"
+	ReturnStatusReturned    = "DEVOLVIDO"
+	ReturnStatusNotReturned = "NAO_REALIZADO"
+)
+
+// Status das recorrências do Pix Automático
+var recurrenceStatuses = map[string]bool{
+	"CRIADA":    true,
+	"APROVADA":  true,
+	"REJEITADA": true,
+	"EXPIRADA":  true,
+	"CANCELADA": true,
+}
+
+// Status das cobranças do Pix Automático
+var chargeStatuses = map[string]bool{
+	"CRIADA":    true,
+	"ATIVA":     true,
+	"CONCLUIDA": true,
+	"EXPIRADA":  true,
+	"REJEITADA": true,
+	"CANCELADA": true,
+}
+
+// Amount é um valor monetário no formato da API Pix ("110.00"), sempre
+// positivo e com duas casas decimais
+type Amount string
+
+// UnmarshalJSON aceita apenas strings no formato da API Pix
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("valor deve ser uma string no formato 0.00: %s", data)
+	}
+	if !amountPattern.MatchString(value) {
+		return fmt.Errorf("valor inválido: %q (use o formato 0.00)", value)
+	}
+	if strings.Trim(value, "0.") == "" {
+		return fmt.Errorf("valor deve ser maior que zero: %q", value)
+	}
+
+	*a = Amount(value)
+	return nil
+}
+
+// Cents retorna o valor em centavos
+func (a Amount) Cents() int64 {
+	cents, _ := strconv.ParseInt(strings.Replace(string(a), ".", "", 1), 10, 64)
+	return cents
+}
+
+// Date é uma data sem horário (AAAA-MM-DD), usada nos vencimentos
+type Date string
+
+// UnmarshalJSON aceita apenas datas válidas no formato AAAA-MM-DD
+func (d *Date) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("data deve ser uma string AAAA-MM-DD: %s", data)
+	}
+	if _, err := time.Parse("2006-01-02", value); err != nil {
+		return fmt.Errorf("data inválida: %q (use AAAA-MM-DD)", value)
+	}
+
+	*d = Date(value)
+	return nil
+}
+
+// PixNotification é a notificação de Pix recebidos: {"pix": [...]}
+type PixNotification struct {
+	Pix []Pix `json:"pix"`
+}
+
+// Pix é um Pix recebido. Nas notificações de Pix enviados pela API a EFI
+// acrescenta o tipo (SOLICITACAO) e o status do envio.
+type Pix struct {
+	EndToEndID       string          `json:"endToEndId"`
+	TxID             string          `json:"txid,omitempty"`
+	Chave            string          `json:"chave,omitempty"`
+	Tipo             string          `json:"tipo,omitempty"`
+	Status           string          `json:"status,omitempty"`
+	Valor            Amount          `json:"valor"`
+	Horario          time.Time       `json:"horario"`
+	InfoPagador      string          `json:"infoPagador,omitempty"`
+	ComponentesValor json.RawMessage `json:"componentesValor,omitempty"`
+	Devolucoes       []PixReturn     `json:"devolucoes,omitempty"`
+	GnExtras         json.RawMessage `json:"gnExtras,omitempty"`
+}
+
+// PixReturn é uma devolução de um Pix recebido
+type PixReturn struct {
+	ID        string          `json:"id"`
+	RtrID     string          `json:"rtrId"`
+	Valor     Amount          `json:"valor"`
+	Natureza  string          `json:"natureza,omitempty"`
+	Descricao string          `json:"descricao,omitempty"`
+	Motivo    string          `json:"motivo,omitempty"`
+	Horario   PixReturnTimes  `json:"horario"`
+	Status    string          `json:"status"`
+	GnExtras  json.RawMessage `json:"gnExtras,omitempty"`
+}
+
+// PixReturnTimes registra quando a devolução foi solicitada e liquidada
+type PixReturnTimes struct {
+	Solicitacao time.Time  `json:"solicitacao"`
+	Liquidacao  *time.Time `json:"liquidacao,omitempty"`
+}
+
+// RecNotification é a notificação de recorrências do Pix Automático:
+// {"rec": [...]}
+type RecNotification struct {
+	Rec []Recurrence `json:"rec"`
+}
+
+// Recurrence é a situação de uma recorrência do Pix Automático
+type Recurrence struct {
+	IDRec               string           `json:"idRec"`
+	Status              string           `json:"status"`
+	Valor               *RecurrenceValue `json:"valor,omitempty"`
+	Vinculo             json.RawMessage  `json:"vinculo,omitempty"`
+	Calendario          json.RawMessage  `json:"calendario,omitempty"`
+	Recebedor           json.RawMessage  `json:"recebedor,omitempty"`
+	Pagador             json.RawMessage  `json:"pagador,omitempty"`
+	PoliticaRetentativa string           `json:"politicaRetentativa,omitempty"`
+	Loc                 json.RawMessage  `json:"loc,omitempty"`
+	Ativacao            json.RawMessage  `json:"ativacao,omitempty"`
+	Encerramento        json.RawMessage  `json:"encerramento,omitempty"`
+	Atualizacao         []StatusChange   `json:"atualizacao,omitempty"`
+	GnExtras            json.RawMessage  `json:"gnExtras,omitempty"`
+}
+
+// RecurrenceValue é o valor fixo da recorrência ou o mínimo aceito pelo
+// recebedor quando o valor é variável
+type RecurrenceValue struct {
+	ValorRec             *Amount `json:"valorRec,omitempty"`
+	ValorMinimoRecebedor *Amount `json:"valorMinimoRecebedor,omitempty"`
+}
+
+// CobrNotification é a notificação de cobranças do Pix Automático:
+// {"cobr": [...]}
+type CobrNotification struct {
+	Cobr []AutomaticCharge `json:"cobr"`
+}
+
+// AutomaticCharge é a situação de uma cobrança do Pix Automático
+type AutomaticCharge struct {
+	IDRec               string          `json:"idRec"`
+	TxID                string          `json:"txid"`
+	Status              string          `json:"status"`
+	InfoAdicional       string          `json:"infoAdicional,omitempty"`
+	Calendario          ChargeCalendar  `json:"calendario"`
+	Valor               ChargeValue     `json:"valor"`
+	AjusteDiaUtil       *bool           `json:"ajusteDiaUtil,omitempty"`
+	Devedor             json.RawMessage `json:"devedor,omitempty"`
+	Recebedor           json.RawMessage `json:"recebedor,omitempty"`
+	PoliticaRetentativa string          `json:"politicaRetentativa,omitempty"`
+	Tentativas          json.RawMessage `json:"tentativas,omitempty"`
+	Encerramento        json.RawMessage `json:"encerramento,omitempty"`
+	Atualizacao         []StatusChange  `json:"atualizacao,omitempty"`
+	GnExtras            json.RawMessage `json:"gnExtras,omitempty"`
+}
+
+// ChargeCalendar guarda as datas da cobrança
+type ChargeCalendar struct {
+	Criacao          *time.Time `json:"criacao,omitempty"`
+	DataDeVencimento Date       `json:"dataDeVencimento"`
+}
+
+// ChargeValue é o valor da cobrança
+type ChargeValue struct {
+	Original Amount `json:"original"`
+}
+
+// StatusChange é uma entrada do histórico de status de recorrências e
+// cobranças
+type StatusChange struct {
+	Status string    `json:"status"`
+	Data   time.Time `json:"data"`
+}
+
+// DecodePixNotification decodifica e valida uma notificação de Pix recebidos
+func DecodePixNotification(data []byte) (*PixNotification, error) {
+	var notification PixNotification
+	if err := decodeStrict(data, &notification); err != nil {
+		return nil, err
+	}
+	if err := notification.Validate(); err != nil {
+		return nil, err
+	}
+	return &notification, nil
+}
+
+// DecodeRecNotification decodifica e valida uma notificação de recorrências
+func DecodeRecNotification(data []byte) (*RecNotification, error) {
+	var notification RecNotification
+	if err := decodeStrict(data, &notification); err != nil {
+		return nil, err
+	}
+	if err := notification.Validate(); err != nil {
+		return nil, err
+	}
+	return &notification, nil
+}
+
+// DecodeCobrNotification decodifica e valida uma notificação de cobranças
+func DecodeCobrNotification(data []byte) (*CobrNotification, error) {
+	var notification CobrNotification
+	if err := decodeStrict(data, &notification); err != nil {
+		return nil, err
+	}
+	if err := notification.Validate(); err != nil {
+		return nil, err
+	}
+	return &notification, nil
+}
+
+// decodeStrict decodifica um único objeto JSON recusando campos desconhecidos
+func decodeStrict(data []byte, out interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(out); err != nil {
+		return fmt.Errorf("payload inválido: %v", err)
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return fmt.Errorf("payload inválido: conteúdo após o objeto JSON")
+	}
+	return nil
+}
+
+// Validate confere os campos obrigatórios e os formatos dos Pix recebidos
+func (n *PixNotification) Validate() error {
+	if len(n.Pix) == 0 {
+		return fmt.Errorf("pix: lista vazia")
+	}
+
+	for i, pix := range n.Pix {
+		field := fmt.Sprintf("pix[%d]", i)
+		if !endToEndIDPattern.MatchString(pix.EndToEndID) {
+			return fmt.Errorf("%s.endToEndId inválido: %q", field, pix.EndToEndID)
+		}
+		if pix.TxID != "" && !txidPattern.MatchString(pix.TxID) {
+			return fmt.Errorf("%s.txid inválido: %q", field, pix.TxID)
+		}
+		if pix.Valor == "" {
+			return fmt.Errorf("%s.valor é obrigatório", field)
+		}
+		if pix.Horario.IsZero() {
+			return fmt.Errorf("%s.horario é obrigatório", field)
+		}
+
+		for j, ret := range pix.Devolucoes {
+			if err := ret.validate(fmt.Sprintf("%s.devolucoes[%d]", field, j)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (r PixReturn) validate(field string) error {
+	if r.ID == "" {
+		return fmt.Errorf("%s.id é obrigatório", field)
+	}
+	if !returnIDPattern.MatchString(r.RtrID) {
+		return fmt.Errorf("%s.rtrId inválido: %q", field, r.RtrID)
+	}
+	if r.Valor == "" {
+		return fmt.Errorf("%s.valor é obrigatório", field)
+	}
+	if r.Horario.Solicitacao.IsZero() {
+		return fmt.Errorf("%s.horario.solicitacao é obrigatório", field)
+	}
+	if r.Horario.Liquidacao != nil && r.Horario.Liquidacao.Before(r.Horario.Solicitacao) {
+		return fmt.Errorf("%s.horario.liquidacao anterior à solicitação", field)
+	}
+
+	switch r.Status {
+	case ReturnStatusProcessing, ReturnStatusReturned, ReturnStatusNotReturned:
+		return nil
+	default:
+		return fmt.Errorf("%s.status inválido: %q", field, r.Status)
+	}
+}
+
+// Validate confere os campos obrigatórios e os formatos das recorrências
+func (n *RecNotification) Validate() error {
+	if len(n.Rec) == 0 {
+		return fmt.Errorf("rec: lista vazia")
+	}
+
+	for i, rec := range n.Rec {
+		field := fmt.Sprintf("rec[%d]", i)
+		if !recIDPattern.MatchString(rec.IDRec) {
+			return fmt.Errorf("%s.idRec inválido: %q", field, rec.IDRec)
+		}
+		if !recurrenceStatuses[rec.Status] {
+			return fmt.Errorf("%s.status inválido: %q", field, rec.Status)
+		}
+		if rec.Valor != nil && rec.Valor.ValorRec != nil && rec.Valor.ValorMinimoRecebedor != nil {
+			return fmt.Errorf("%s.valor: informe valorRec ou valorMinimoRecebedor, não ambos", field)
+		}
+		if err := validateStatusChanges(field, rec.Atualizacao, recurrenceStatuses); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Validate confere os campos obrigatórios e os formatos das cobranças
+func (n *CobrNotification) Validate() error {
+	if len(n.Cobr) == 0 {
+		return fmt.Errorf("cobr: lista vazia")
+	}
+
+	for i, cobr := range n.Cobr {
+		field := fmt.Sprintf("cobr[%d]", i)
+		if !recIDPattern.MatchString(cobr.IDRec) {
+			return fmt.Errorf("%s.idRec inválido: %q", field, cobr.IDRec)
+		}
+		if !txidPattern.MatchString(cobr.TxID) {
+			return fmt.Errorf("%s.txid inválido: %q", field, cobr.TxID)
+		}
+		if !chargeStatuses[cobr.Status] {
+			return fmt.Errorf("%s.status inválido: %q", field, cobr.Status)
+		}
+		if cobr.Valor.Original == "" {
+			return fmt.Errorf("%s.valor.original é obrigatório", field)
+		}
+		if cobr.Calendario.DataDeVencimento == "" {
+			return fmt.Errorf("%s.calendario.dataDeVencimento é obrigatório", field)
+		}
+		if err := validateStatusChanges(field, cobr.Atualizacao, chargeStatuses); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateStatusChanges confere o histórico de status, que deve ter datas
+// preenchidas e em ordem cronológica
+func validateStatusChanges(field string, changes []StatusChange, statuses map[string]bool) error {
+	for i, change := range changes {
+		if !statuses[change.Status] {
+			return fmt.Errorf("%s.atualizacao[%d].status inválido: %q", field, i, change.Status)
+		}
+		if change.Data.IsZero() {
+			return fmt.Errorf("%s.atualizacao[%d].data é obrigatória", field, i)
+		}
+		if i > 0 && change.Data.Before(changes[i-1].Data) {
+			return fmt.Errorf("%s.atualizacao[%d].data anterior à atualização anterior", field, i)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// update regrava os arquivos .golden com o resultado atual:
+// go test ./models -run TestNotificationGolden -update
+var update = flag.Bool("update", false, "regrava os arquivos .golden de testdata")
+
+// TestNotificationGolden decodifica cada payload de testdata (exemplos da
+// documentação da EFI e variações inválidas) e compara com o .golden ao lado:
+// a notificação decodificada em JSON ou a mensagem de erro
+func TestNotificationGolden(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inputs) == 0 {
+		t.Fatal("nenhum payload em testdata")
+	}
+
+	for _, input := range inputs {
+		name := strings.TrimSuffix(filepath.Base(input), ".json")
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := decodeGolden(t, name, data)
+			golden := strings.TrimSuffix(input, ".json") + ".golden"
+
+			if *update {
+				if err := os.WriteFile(golden, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v (rode com -update para gerar)", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("resultado difere de %s\n--- obtido ---\n%s--- esperado ---\n%s", golden, got, want)
+			}
+		})
+	}
+}
+
+// decodeGolden decodifica o payload pelo tipo indicado no prefixo do nome do
+// arquivo (pix_, rec_ ou cobr_) e formata o resultado para o .golden
+func decodeGolden(t *testing.T, name string, data []byte) []byte {
+	t.Helper()
+
+	var (
+		notification interface{}
+		err          error
+	)
+	switch kind := strings.SplitN(name, "_", 2)[0]; kind {
+	case "pix":
+		notification, err = DecodePixNotification(data)
+	case "rec":
+		notification, err = DecodeRecNotification(data)
+	case "cobr":
+		notification, err = DecodeCobrNotification(data)
+	default:
+		t.Fatalf("tipo de notificação desconhecido: %s", kind)
+	}
+
+	if err != nil {
+		return []byte("erro: " + err.Error() + "\n")
+	}
+
+	out, err := json.MarshalIndent(notification, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return append(out, '\n')
+}
+
+func TestAmountCents(t *testing.T) {
+	cases := map[Amount]int64{
+		"0.01":   1,
+		"7.89":   789,
+		"110.00": 11000,
+	}
+
+	for amount, want := range cases {
+		if got := amount.Cents(); got != want {
+			t.Errorf("Amount(%q).Cents() = %d, esperado %d", amount, got, want)
+		}
+	}
+}
+
+func TestDecodeRejectsTrailingContent(t *testing.T) {
+	data := []byte(`{"rec":[{"idRec":"RR1234567820240115abcdefghijk","status":"CRIADA"}]} {}`)
+
+	if _, err := DecodeRecNotification(data); err == nil {
+		t.Fatal("esperado erro para conteúdo após o objeto JSON")
+	}
+}
@@ -16,10 +16,21 @@ const (
 	WebhookTypeCharge     WebhookType = "charge"
 	WebhookTypeRecurrence WebhookType = "recurrence"
 )
+
 type WebhookCommand struct {
-	Type    WebhookType
-	Action  string
-	URL     string
-	Params  map[string]string
-	Body    map[string]interface{}
-}
\ No newline at end of file
+	Type   WebhookType
+	Action string
+	URL    string
+	Params map[string]string
+	Body   map[string]interface{}
+	// SkipMTLS envia x-skip-mtls-checking na configuração: a EFI deixa de
+	// exigir mTLS do servidor que recebe as notificações
+	SkipMTLS bool
+}
+
+// WebhookOptions são as opções de configuração de um webhook
+type WebhookOptions struct {
+	// SkipMTLS dispensa a validação mTLS do receptor pela EFI. Só deve ser
+	// usado quando o receptor não consegue exigir o certificado da EFI.
+	SkipMTLS bool
+}
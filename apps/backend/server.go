@@ -1,94 +1,209 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"syscall"
+	"time"
 
 	"pix_cli/controllers"
+	"pix_cli/logging"
 	"pix_cli/services"
 )
 
 type Server struct {
-	controller *controllers.WebhookController
-	port       int
+	registry *services.ServiceRegistry
+	monitor  *services.CertificateMonitor
+	store    *services.Store
+	audit    *services.AuditLog
+	history  *services.WebhookHistory
+	events   *services.EventStore
+	users    *services.UserStore
+	sessions *services.SessionManager
+	cors     *corsPolicy
+	port     int
+
+	webhookSettings *services.WebhookSettingsStore
+	// receiver configura a autenticação das notificações recebidas da EFI
+	receiver *receiverConfig
+	// eventRetention é por quanto tempo os eventos recebidos são guardados
+	// (PIX_EVENT_RETENTION); zero mantém todos
+	eventRetention time.Duration
+
+	// apiToken é o token estático aceito em Authorization: Bearer (PIX_API_TOKEN)
+	apiToken string
+	// authDisabled libera a API sem autenticação (PIX_AUTH_DISABLED=true),
+	// apenas para desenvolvimento local
+	authDisabled bool
 }
 
-func NewServer(controller *controllers.WebhookController, port int) *Server {
+func NewServer(registry *services.ServiceRegistry, monitor *services.CertificateMonitor, store *services.Store, sessions *services.SessionManager, cors *corsPolicy, receiver *receiverConfig, eventRetention time.Duration, port int) *Server {
 	return &Server{
-		controller: controller,
-		port:       port,
+		registry:        registry,
+		monitor:         monitor,
+		store:           store,
+		audit:           services.NewAuditLog(store),
+		history:         services.NewWebhookHistory(store),
+		events:          services.NewEventStore(store),
+		users:           services.NewUserStore(store),
+		sessions:        sessions,
+		cors:            cors,
+		port:            port,
+		webhookSettings: services.NewWebhookSettingsStore(store),
+		receiver:        receiver,
+		eventRetention:  eventRetention,
+		apiToken:        os.Getenv("PIX_API_TOKEN"),
+		authDisabled:    os.Getenv("PIX_AUTH_DISABLED") == "true",
 	}
 }
 
-// reloadEFIService recarrega o serviço EFI com as credenciais atualizadas
-func (s *Server) reloadEFIService() error {
-	credentials, err := services.LoadCredentials()
+// controllerFor resolve o controller do ambiente da requisição. Cada chamada
+// devolve um controller próprio sobre o serviço em cache do ambiente, então
+// requisições concorrentes de ambientes diferentes nunca compartilham estado
+// mutável do Server.
+func (s *Server) controllerFor(env string) (*controllers.WebhookController, error) {
+	efiService, err := s.registry.Get(env)
 	if err != nil {
-		return fmt.Errorf("erro ao recarregar credenciais: %v", err)
+		slog.Error("erro ao obter serviço EFI", "env", env, "error", err)
+		return nil, fmt.Errorf("erro ao recarregar serviço EFI: %w", err)
 	}
 
-	efiService, err := services.NewEFIService(credentials)
-	if err != nil {
-		return fmt.Errorf("erro ao recriar serviço EFI: %v", err)
+	return controllers.NewWebhookController(efiService), nil
+}
+
+func (s *Server) Start() error {
+	http.HandleFunc("/api/", s.withRequestID(s.handleCORS(s.requireAuth(s.handleAPI))))
+
+	if s.authDisabled {
+		slog.Warn("autenticação da API desabilitada (PIX_AUTH_DISABLED=true)")
+	} else if s.apiToken == "" && s.users.Count() == 0 {
+		slog.Warn("nenhum usuário nem PIX_API_TOKEN configurado; crie o primeiro administrador com --create-admin")
 	}
 
-	// Recria o controller com o novo serviço
-	s.controller = controllers.NewWebhookController(efiService)
-	return nil
-}
+	http.HandleFunc("/health", s.withRequestID(s.handleHealth))
 
-func (s *Server) reloadEFIServiceWithEnv(env string) error {
-	log.Printf("🔄 [reloadEFIServiceWithEnv] Recarregando serviço EFI para ambiente: %s", env)
+	// Receptor das notificações da EFI, sem autenticação da API. Com
+	// PIX_RECEIVER_ADDR ele sai da porta da API para o listener com mTLS.
+	addr := fmt.Sprintf(":%d", s.port)
+	webhooksURL := "http://localhost" + addr + receiverPath + "<env>"
+	if !s.receiver.tlsEnabled() {
+		http.HandleFunc(receiverPath, s.withRequestID(s.handleReceiver))
+		slog.Warn("receptor de webhooks sem mTLS; configure PIX_RECEIVER_ADDR para verificar o certificado da EFI")
+	} else {
+		webhooksURL = "https://localhost" + s.receiver.tlsAddr + receiverPath + "<env>"
+	}
 
-	credentials, err := services.LoadCredentialsWithEnv(env)
-	if err != nil {
-		log.Printf("❌ [reloadEFIServiceWithEnv] Erro ao carregar credenciais: %v", err)
-		return fmt.Errorf("erro ao recarregar credenciais: %v", err)
+	slog.Info("servidor iniciado", "port", s.port, "api", "http://localhost"+addr, "webhooks", webhooksURL)
+
+	// O contexto base é cancelado no encerramento do processo, interrompendo
+	// as chamadas à EFI que ainda estiverem em andamento
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	server := &http.Server{
+		Addr:        addr,
+		BaseContext: func(net.Listener) context.Context { return ctx },
 	}
-	log.Printf("✅ [reloadEFIServiceWithEnv] Credenciais carregadas: %+v", credentials)
 
-	efiService, err := services.NewEFIService(credentials)
-	if err != nil {
-		log.Printf("❌ [reloadEFIServiceWithEnv] Erro ao criar serviço EFI: %v", err)
-		return fmt.Errorf("erro ao recriar serviço EFI: %v", err)
+	// Verifica a validade dos certificados em segundo plano até o encerramento
+	go s.monitor.Run(ctx)
+
+	// Remove os eventos recebidos fora da retenção, para que o armazenamento
+	// (mantido em memória) não cresça sem limite
+	go s.events.RunRetention(ctx, s.eventRetention)
+
+	// Uma falha do listener do receptor encerra também o servidor da API
+	var receiver *http.Server
+	receiverErr := make(chan error, 1)
+	if s.receiver.tlsEnabled() {
+		receiver = s.newReceiverServer()
+		receiver.BaseContext = server.BaseContext
+
+		go func() {
+			if err := receiver.ListenAndServeTLS(s.receiver.certFile, s.receiver.keyFile); err != nil && err != http.ErrServerClosed {
+				receiverErr <- fmt.Errorf("erro no receptor de webhooks: %w", err)
+				stop()
+			}
+		}()
 	}
-	log.Printf("✅ [reloadEFIServiceWithEnv] Serviço EFI criado com sucesso")
 
-	// Recria o controller com o novo serviço
-	s.controller = controllers.NewWebhookController(efiService)
-	log.Printf("✅ [reloadEFIServiceWithEnv] Controller recriado com sucesso")
-	return nil
-}
+	go func() {
+		<-ctx.Done()
+		slog.Info("encerrando servidor")
 
-func (s *Server) Start() error {
-	http.HandleFunc("/api/", s.handleCORS(s.handleAPI))
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if receiver != nil {
+			receiver.Shutdown(shutdownCtx)
+		}
+		server.Shutdown(shutdownCtx)
+	}()
 
-	http.HandleFunc("/health", s.handleHealth)
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return err
+	}
 
-	addr := fmt.Sprintf(":%d", s.port)
-	log.Printf("🚀 Servidor iniciado na porta %d", s.port)
-	log.Printf("📡 API disponível em: http://localhost%s", addr)
+	select {
+	case err := <-receiverErr:
+		return err
+	default:
+		return nil
+	}
+}
 
-	return http.ListenAndServe(addr, nil)
+// requestIDPattern limita o X-Request-ID aceito do cliente a algo seguro de
+// ecoar em cabeçalhos e logs
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// statusRecorder guarda o status escrito pelo handler para o log de acesso
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
 }
-func (s *Server) handleCORS(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
-			return
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// withRequestID atribui um request ID a cada requisição (reaproveitando o
+// X-Request-ID enviado pelo cliente, se válido), devolve-o no cabeçalho da
+// resposta e o propaga pelo contexto até os logs das chamadas à EFI
+func (s *Server) withRequestID(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get("X-Request-ID")
+		if !requestIDPattern.MatchString(requestID) {
+			requestID = logging.NewRequestID()
 		}
 
-		next(w, r)
+		w.Header().Set("X-Request-ID", requestID)
+		ctx := logging.WithRequestID(r.Context(), requestID)
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+
+		next(recorder, r.WithContext(ctx))
+
+		slog.InfoContext(ctx, "requisição atendida",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"env", r.URL.Query().Get("env"),
+			"status", recorder.status,
+			"duration", time.Since(start),
+			"remote_addr", r.RemoteAddr,
+		)
 	}
 }
 
@@ -98,27 +213,65 @@ func (s *Server) handleAPI(w http.ResponseWriter, r *http.Request) {
 
 	path := r.URL.Path
 
+	if perm, ok := routePermissions[r.Method+" "+path]; ok {
+		if !s.authorize(w, r, perm) {
+			return
+		}
+	}
+
 	switch {
+	case path == "/api/auth/login" && r.Method == "POST":
+		s.handleLogin(w, r)
+	case path == "/api/auth/logout" && r.Method == "POST":
+		s.handleLogout(w, r)
+	case path == "/api/auth/me" && r.Method == "GET":
+		s.handleMe(w, r)
 	case path == "/api/webhook/config" && r.Method == "POST":
 		s.handleConfigWebhook(w, r)
 	case path == "/api/webhook/list" && r.Method == "GET":
 		s.handleListWebhook(w, r)
 	case path == "/api/webhook/delete" && r.Method == "DELETE":
 		s.handleDeleteWebhook(w, r)
+	case path == "/api/webhook/rotate-secret" && r.Method == "POST":
+		s.handleRotateWebhookSecret(w, r)
+	case path == "/api/webhook/history" && r.Method == "GET":
+		s.handleWebhookHistory(w, r)
+	case path == "/api/events" && r.Method == "GET":
+		s.handleEvents(w, r)
+	case path == "/api/events/stats" && r.Method == "GET":
+		s.handleEventStats(w, r)
 	case path == "/api/test-connection" && r.Method == "GET":
 		s.handleTestConnection(w, r)
 	case path == "/api/status" && r.Method == "GET":
 		s.handleStatus(w, r)
 	case path == "/api/upload-certificate" && r.Method == "POST":
 		s.handleUploadCertificate(w, r)
+	case path == "/api/upload-certificate-pem" && r.Method == "POST":
+		s.handleUploadCertificatePEM(w, r)
 	case path == "/api/save-credentials" && r.Method == "POST":
 		s.handleSaveCredentials(w, r)
 	case path == "/api/load-credentials" && r.Method == "GET":
 		s.handleLoadCredentials(w, r)
+	case path == "/api/reveal-credentials" && r.Method == "POST":
+		s.handleRevealCredentials(w, r)
 	case path == "/api/certificate-status" && r.Method == "GET":
 		s.handleCertificateStatus(w, r)
+	case path == "/api/certificates" && r.Method == "GET":
+		s.handleCertificates(w, r)
 	case path == "/api/reload-service" && r.Method == "POST":
 		s.handleReloadService(w, r)
+	case path == "/api/users" && r.Method == "GET":
+		s.handleListUsers(w, r)
+	case path == "/api/users" && r.Method == "POST":
+		s.handleCreateUser(w, r)
+	case path == "/api/users/role" && r.Method == "PUT":
+		s.handleSetUserRole(w, r)
+	case path == "/api/users" && r.Method == "DELETE":
+		s.handleDeleteUser(w, r)
+	case path == "/api/roles" && r.Method == "GET":
+		s.handleListRoles(w, r)
+	case path == "/api/audit" && r.Method == "GET":
+		s.handleAudit(w, r)
 	default:
 		s.sendError(w, "Endpoint não encontrado", http.StatusNotFound)
 	}
@@ -126,15 +279,14 @@ func (s *Server) handleAPI(w http.ResponseWriter, r *http.Request) {
 
 // handleConfigWebhook configura um webhook
 func (s *Server) handleConfigWebhook(w http.ResponseWriter, r *http.Request) {
-	if s.controller == nil {
-		s.sendError(w, "Serviço EFI não está disponível - configure as credenciais", http.StatusServiceUnavailable)
-		return
-	}
-
 	var req struct {
 		Type string `json:"type"`
 		URL  string `json:"url"`
 		Env  string `json:"env"`
+		// SkipMTLS configura o webhook com x-skip-mtls-checking
+		SkipMTLS bool `json:"skipMtls"`
+		// HMAC acrescenta à URL um segredo gerado, exigido pelo receptor
+		HMAC bool `json:"hmac"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -154,37 +306,83 @@ func (s *Server) handleConfigWebhook(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Recarrega o serviço com o ambiente correto
-	if err := s.reloadEFIServiceWithEnv(env); err != nil {
+	// Resolve o controller do ambiente da requisição
+	controller, err := s.controllerFor(env)
+	if err != nil {
 		s.sendError(w, fmt.Sprintf("Erro ao recarregar serviço: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	webhookType, err := s.controller.ValidateWebhookType(req.Type)
+	webhookType, err := controller.ValidateWebhookType(req.Type)
+	if err != nil {
+		s.sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Com o listener HTTPS, a notificação sem certificado de cliente só é
+	// aceita com o segredo do webhook; sem hmac, skip-mtls liberaria o
+	// receptor para qualquer origem
+	if req.SkipMTLS && !req.HMAC && s.receiver.tlsEnabled() {
+		s.sendErrorCode(w, "skipMtls exige hmac quando o receptor usa mTLS (PIX_RECEIVER_ADDR)", "skip_mtls_requires_hmac", http.StatusBadRequest, nil)
+		return
+	}
+
+	// As opções ficam gravadas para o receptor saber como a EFI entregará as
+	// notificações: sem certificado de cliente (skip-mtls) e com o segredo
+	// acrescentado à URL (hmac)
+	settings := services.WebhookSettings{
+		Env:        env,
+		Type:       string(webhookType),
+		WebhookURL: services.StripWebhookSecret(req.URL),
+		SkipMTLS:   req.SkipMTLS,
+		HMAC:       req.HMAC,
+	}
+	if req.HMAC {
+		secret, err := services.NewWebhookSecret()
+		if err != nil {
+			s.sendError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// Ao reconfigurar, o segredo anterior segue aceito durante a transição
+		if previous, found, _ := s.webhookSettings.Get(env, string(webhookType)); found && previous.HMAC {
+			settings.Secret = previous.Secret
+		}
+		settings.Rotate(secret, s.receiver.secretGrace, time.Now())
+	}
+
+	registeredURL, err := settings.RegisteredURL()
 	if err != nil {
 		s.sendError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	if err := s.controller.ConfigWebhook(webhookType, req.URL); err != nil {
-		s.sendError(w, err.Error(), http.StatusInternalServerError)
+	entry := s.auditEntry(r, env, services.AuditConfigWebhook)
+	entry.Before = webhookState(r.Context(), controller, webhookType)
+
+	response, err := s.configureWebhook(r.Context(), controller, webhookType, settings)
+	entry.EFIStatus = efiStatus(response, err)
+	if err != nil {
+		s.auditOutcome(entry, err)
+		s.sendEFIError(w, err)
 		return
 	}
 
+	entry.After = map[string]interface{}{"type": string(webhookType), "configured": true, "webhookUrl": registeredURL, "skip_mtls": req.SkipMTLS, "hmac": req.HMAC}
+	s.auditOutcome(entry, nil)
+	s.recordWebhookChange(r, env, string(webhookType), "config", registeredURL)
+
 	s.sendSuccess(w, map[string]interface{}{
-		"message": fmt.Sprintf("Webhook %s configurado com sucesso", req.Type),
-		"type":    req.Type,
-		"url":     req.URL,
+		"message":   fmt.Sprintf("Webhook %s configurado com sucesso", req.Type),
+		"type":      req.Type,
+		"url":       services.RedactURL(registeredURL),
+		"skip_mtls": req.SkipMTLS,
+		"hmac":      req.HMAC,
 	})
 }
 
 // handleListWebhook lista webhooks
 func (s *Server) handleListWebhook(w http.ResponseWriter, r *http.Request) {
-	if s.controller == nil {
-		s.sendError(w, "Serviço EFI não está disponível - configure as credenciais", http.StatusServiceUnavailable)
-		return
-	}
-
 	webhookType := r.URL.Query().Get("type")
 	if webhookType == "" {
 		s.sendError(w, "Tipo de webhook é obrigatório", http.StatusBadRequest)
@@ -203,23 +401,24 @@ func (s *Server) handleListWebhook(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Recarrega o serviço com o ambiente correto
-	if err := s.reloadEFIServiceWithEnv(env); err != nil {
+	// Resolve o controller do ambiente da requisição
+	controller, err := s.controllerFor(env)
+	if err != nil {
 		s.sendError(w, fmt.Sprintf("Erro ao recarregar serviço: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	wt, err := s.controller.ValidateWebhookType(webhookType)
+	wt, err := controller.ValidateWebhookType(webhookType)
 	if err != nil {
 		s.sendError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	// Chama o serviço diretamente para obter os dados
-	response, err := s.controller.GetEFIService().ListWebhook(wt)
+	response, err := controller.GetEFIService().ListWebhookContext(r.Context(), wt)
 	if err != nil {
 		// Se for 404, significa que não há webhook configurado (normal)
-		if response != nil && response.Code == 404 {
+		if services.IsNotFound(err) {
 			s.sendSuccess(w, map[string]interface{}{
 				"type":    webhookType,
 				"exists":  false,
@@ -227,35 +426,21 @@ func (s *Server) handleListWebhook(w http.ResponseWriter, r *http.Request) {
 			})
 			return
 		}
-		s.sendError(w, err.Error(), http.StatusInternalServerError)
+		s.sendEFIError(w, err)
 		return
 	}
 
-	// Se retornou 200, retorna os dados do webhook
-	if response.Code == 200 {
-		s.sendSuccess(w, map[string]interface{}{
-			"type":       webhookType,
-			"exists":     true,
-			"webhookUrl": response.Data["webhookUrl"],
-			"criacao":    response.Data["criacao"],
-			"message":    fmt.Sprintf("Webhook %s encontrado", webhookType),
-		})
-	} else {
-		s.sendSuccess(w, map[string]interface{}{
-			"type":    webhookType,
-			"exists":  false,
-			"message": fmt.Sprintf("Nenhum webhook %s configurado", webhookType),
-		})
-	}
+	s.sendSuccess(w, map[string]interface{}{
+		"type":       webhookType,
+		"exists":     true,
+		"webhookUrl": services.RedactURL(fmt.Sprint(response.Data["webhookUrl"])),
+		"criacao":    response.Data["criacao"],
+		"message":    fmt.Sprintf("Webhook %s encontrado", webhookType),
+	})
 }
 
 // handleDeleteWebhook remove um webhook
 func (s *Server) handleDeleteWebhook(w http.ResponseWriter, r *http.Request) {
-	if s.controller == nil {
-		s.sendError(w, "Serviço EFI não está disponível - configure as credenciais", http.StatusServiceUnavailable)
-		return
-	}
-
 	var req struct {
 		Type string `json:"type"`
 		Env  string `json:"env"`
@@ -278,23 +463,38 @@ func (s *Server) handleDeleteWebhook(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Recarrega o serviço com o ambiente correto
-	if err := s.reloadEFIServiceWithEnv(env); err != nil {
+	// Resolve o controller do ambiente da requisição
+	controller, err := s.controllerFor(env)
+	if err != nil {
 		s.sendError(w, fmt.Sprintf("Erro ao recarregar serviço: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	webhookType, err := s.controller.ValidateWebhookType(req.Type)
+	webhookType, err := controller.ValidateWebhookType(req.Type)
 	if err != nil {
 		s.sendError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	if err := s.controller.DeleteWebhook(webhookType); err != nil {
-		s.sendError(w, err.Error(), http.StatusInternalServerError)
+	entry := s.auditEntry(r, env, services.AuditDeleteWebhook)
+	entry.Before = webhookState(r.Context(), controller, webhookType)
+
+	response, err := controller.DeleteWebhookContext(r.Context(), webhookType)
+	entry.EFIStatus = efiStatus(response, err)
+	if err != nil {
+		s.auditOutcome(entry, err)
+		s.sendEFIError(w, err)
 		return
 	}
 
+	entry.After = map[string]interface{}{"type": string(webhookType), "configured": false}
+	s.auditOutcome(entry, nil)
+	s.recordWebhookChange(r, env, string(webhookType), "delete", "")
+
+	if err := s.webhookSettings.Delete(env, string(webhookType)); err != nil {
+		slog.ErrorContext(r.Context(), "erro ao remover opções do webhook", "env", env, "error", err)
+	}
+
 	s.sendSuccess(w, map[string]interface{}{
 		"message": fmt.Sprintf("Webhook %s removido com sucesso", req.Type),
 		"type":    req.Type,
@@ -303,32 +503,59 @@ func (s *Server) handleDeleteWebhook(w http.ResponseWriter, r *http.Request) {
 
 // handleTestConnection testa conexão com EFI
 func (s *Server) handleTestConnection(w http.ResponseWriter, r *http.Request) {
-	if s.controller == nil {
+	env := r.URL.Query().Get("env")
+	if env == "" {
+		env = "sandbox" // Default to sandbox
+	}
+
+	if env != "sandbox" && env != "production" {
+		s.sendError(w, "Ambiente inválido. Use 'sandbox' ou 'production'", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := s.controllerFor(env); err != nil {
 		s.sendError(w, "Serviço EFI não está disponível - configure as credenciais", http.StatusServiceUnavailable)
 		return
 	}
 
-	// Simula teste de conexão
 	s.sendSuccess(w, map[string]interface{}{
 		"status":  "connected",
 		"message": "Conexão com EFI Pay estabelecida",
+		"env":     env,
 	})
 }
 
 // handleStatus retorna status do sistema
 func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	loaded := s.registry.Loaded()
+
 	efiStatus := "offline"
-	if s.controller != nil {
+	if env := r.URL.Query().Get("env"); env != "" {
+		for _, e := range loaded {
+			if e == env {
+				efiStatus = "online"
+			}
+		}
+	} else if len(loaded) > 0 {
 		efiStatus = "online"
 	}
 
+	store := s.store.Health()
+
+	status := "online"
+	if store.Status != "online" {
+		status = "degraded"
+	}
+
 	s.sendSuccess(w, map[string]interface{}{
-		"status": "online",
+		"status": status,
 		"services": map[string]interface{}{
 			"backend":  "online",
 			"efi":      efiStatus,
-			"database": "online",
+			"database": store.Status,
 		},
+		"store":        store,
+		"environments": loaded,
 	})
 }
 
@@ -375,40 +602,193 @@ func (s *Server) handleUploadCertificate(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Create certs directory if it doesn't exist
-	certsDir := "./certs"
-	if err := os.MkdirAll(certsDir, 0755); err != nil {
-		s.sendError(w, "Erro ao criar diretório", http.StatusInternalServerError)
+	data, err := io.ReadAll(file)
+	if err != nil {
+		s.sendError(w, "Erro ao ler arquivo", http.StatusBadRequest)
+		return
+	}
+
+	// A senha pode vir junto do upload; senão vale a senha já salva nas credenciais
+	password := r.FormValue("password")
+	passwordChanged := password != ""
+	if !passwordChanged {
+		if existing, err := services.LoadCredentialsWithEnv(env); err == nil {
+			password = existing.CertificatePassword
+		}
+	}
+
+	cert, err := services.ParseP12(data, password)
+	if err != nil {
+		s.sendErrorCode(w, fmt.Sprintf("Certificado inválido: %v", err), "certificate_invalid", http.StatusBadRequest, nil)
+		return
+	}
+
+	p12Path, pemCertPath, pemKeyPath := services.CertificatePaths(env)
+	files := map[string][]byte{p12Path: data}
+
+	if passwordChanged {
+		configPath, configBytes, err := credentialsWithCertificatePassword(env, password)
+		if err != nil {
+			s.sendError(w, fmt.Sprintf("Erro ao salvar senha do certificado: %v", err), http.StatusBadRequest)
+			return
+		}
+		files[configPath] = configBytes
+	}
+
+	// O .p12 recém-enviado passa a valer no lugar de um par PEM anterior
+	s.installCertificate(w, r, env, cert, files, []string{pemCertPath, pemKeyPath}, p12Path)
+}
+
+// handleUploadCertificatePEM recebe um par PEM (certificado e chave privada)
+// como alternativa ao .p12. O par enviado tem precedência sobre o .p12.
+func (s *Server) handleUploadCertificatePEM(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		s.sendError(w, "Erro ao processar arquivo", http.StatusBadRequest)
 		return
 	}
 
-	// Save file with environment-specific name
-	certPath := filepath.Join(certsDir, fmt.Sprintf("certificado_%s.p12", env))
-	dst, err := os.Create(certPath)
+	env := r.URL.Query().Get("env")
+	if env == "" {
+		env = "sandbox" // Default to sandbox
+	}
+
+	if env != "sandbox" && env != "production" {
+		s.sendError(w, "Ambiente inválido. Use 'sandbox' ou 'production'", http.StatusBadRequest)
+		return
+	}
+
+	certPEM, err := readFormFile(r, "certificate")
 	if err != nil {
-		s.sendError(w, "Erro ao salvar arquivo", http.StatusInternalServerError)
+		s.sendError(w, "Arquivo de certificado não encontrado", http.StatusBadRequest)
+		return
+	}
+
+	keyPEM, err := readFormFile(r, "key")
+	if err != nil {
+		s.sendError(w, "Arquivo de chave privada não encontrado", http.StatusBadRequest)
+		return
+	}
+
+	// Garante que o par é válido antes de gravar
+	cert, err := services.ParsePEMPair(certPEM, keyPEM)
+	if err != nil {
+		s.sendErrorCode(w, fmt.Sprintf("Par PEM inválido: %v", err), "certificate_invalid", http.StatusBadRequest, nil)
+		return
+	}
+
+	_, certPath, keyPath := services.CertificatePaths(env)
+	s.installCertificate(w, r, env, cert, map[string][]byte{
+		certPath: certPEM,
+		keyPath:  keyPEM,
+	}, nil, certPath)
+}
+
+// installCertificate valida o certificado para o ambiente, grava os arquivos
+// de forma atômica e recarrega o serviço. Se o serviço não subir com o novo
+// certificado, a versão anterior é restaurada. Cada tentativa vai para o log
+// de auditoria.
+func (s *Server) installCertificate(w http.ResponseWriter, r *http.Request, env string, cert tls.Certificate, files map[string][]byte, remove []string, certPath string) {
+	info := services.DescribeCertificate(cert.Leaf)
+
+	entry := s.auditEntry(r, env, services.AuditUploadCertificate)
+	entry.Before = certificateState(env)
+
+	pools, err := services.LoadClientCAs()
+	if err != nil {
+		slog.Error("erro ao carregar CA da EFI", "env", env, "error", err)
+		s.auditOutcome(entry, err)
+		s.sendError(w, fmt.Sprintf("Erro ao carregar CA da EFI: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	chain, err := services.CertificateChain(cert)
+	if err == nil {
+		err = services.ValidateCertificate(chain, env, pools, time.Now())
+	}
+	if err != nil {
+		s.auditOutcome(entry, err)
+		s.sendErrorCode(w, fmt.Sprintf("Certificado recusado: %v", err), "certificate_invalid", http.StatusBadRequest, info)
+		return
+	}
+
+	if err := os.MkdirAll("./certs", 0755); err != nil {
+		s.auditOutcome(entry, err)
+		s.sendError(w, "Erro ao criar diretório", http.StatusInternalServerError)
 		return
 	}
-	defer dst.Close()
 
-	if _, err := io.Copy(dst, file); err != nil {
-		s.sendError(w, "Erro ao copiar arquivo", http.StatusInternalServerError)
+	install, err := services.InstallCertificateFiles(files, remove)
+	if err != nil {
+		slog.Error("erro ao gravar certificado", "env", env, "error", err)
+		s.auditOutcome(entry, err)
+		s.sendError(w, "Erro ao salvar arquivo", http.StatusInternalServerError)
 		return
 	}
 
-	// Recarrega o serviço EFI após o upload do certificado
-	if err := s.reloadEFIServiceWithEnv(env); err != nil {
-		s.sendError(w, "Erro ao recarregar serviço EFI após upload do certificado", http.StatusInternalServerError)
+	// Descarta o serviço em cache e recarrega com o novo certificado
+	s.registry.Invalidate(env)
+	if _, err := s.controllerFor(env); err != nil {
+		slog.Error("serviço não subiu com o novo certificado, restaurando o anterior", "env", env, "error", err)
+
+		if rollbackErr := install.Rollback(); rollbackErr != nil {
+			slog.Error("erro ao restaurar certificado anterior", "env", env, "error", rollbackErr)
+		}
+		s.registry.Invalidate(env)
+
+		s.auditOutcome(entry, err)
+		s.sendErrorCode(w, fmt.Sprintf("Erro ao recarregar serviço EFI com o novo certificado; o certificado anterior foi restaurado: %v", err), "certificate_rejected", http.StatusBadGateway, info)
 		return
 	}
 
+	// Atualiza os avisos de expiração com o certificado recém-instalado
+	s.monitor.Check()
+
+	entry.After = certificateState(env)
+	s.auditOutcome(entry, nil)
+
 	s.sendSuccess(w, map[string]interface{}{
-		"message": fmt.Sprintf("Certificado %s enviado com sucesso", env),
-		"path":    certPath,
-		"env":     env,
+		"message":     fmt.Sprintf("Certificado %s enviado com sucesso", env),
+		"path":        certPath,
+		"env":         env,
+		"certificate": info,
 	})
 }
 
+// credentialsWithCertificatePassword retorna o arquivo de credenciais do
+// ambiente com a senha do certificado atualizada
+func credentialsWithCertificatePassword(env, password string) (string, []byte, error) {
+	configPath := filepath.Join("./config", fmt.Sprintf("credentials_%s.json", env))
+
+	configBytes, err := services.ReadSecretFile(configPath)
+	if err != nil {
+		return "", nil, fmt.Errorf("salve as credenciais do ambiente %s antes de informar a senha", env)
+	}
+
+	configData := map[string]interface{}{}
+	if err := json.Unmarshal(configBytes, &configData); err != nil {
+		return "", nil, fmt.Errorf("erro ao decodificar credenciais do arquivo: %v", err)
+	}
+	configData["certificate_password"] = password
+
+	configBytes, err = json.MarshalIndent(configData, "", "  ")
+	if err != nil {
+		return "", nil, fmt.Errorf("erro ao serializar configuração: %v", err)
+	}
+
+	return configPath, configBytes, nil
+}
+
+// readFormFile lê por completo um arquivo enviado via multipart
+func readFormFile(r *http.Request, field string) ([]byte, error) {
+	file, _, err := r.FormFile(field)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return io.ReadAll(file)
+}
+
 // handleSaveCredentials salva as credenciais em arquivo JSON
 func (s *Server) handleSaveCredentials(w http.ResponseWriter, r *http.Request) {
 	var creds struct {
@@ -416,6 +796,11 @@ func (s *Server) handleSaveCredentials(w http.ResponseWriter, r *http.Request) {
 		ClientSecret string `json:"clientSecret"`
 		Sandbox      bool   `json:"sandbox"`
 		Env          string `json:"env"`
+		// KeepExistingSecret reaproveita o client_secret já salvo, permitindo
+		// trocar o Client ID sem digitar o segredo novamente
+		KeepExistingSecret bool `json:"keepExistingSecret"`
+		// CertificatePassword é a senha do .p12; ausente mantém a senha já salva
+		CertificatePassword *string `json:"certificatePassword"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&creds); err != nil {
@@ -435,15 +820,33 @@ func (s *Server) handleSaveCredentials(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	existing, existingErr := services.LoadCredentialsWithEnv(env)
+
+	entry := s.auditEntry(r, env, services.AuditSaveCredentials)
+	entry.Before = credentialsState(env)
+
+	if creds.KeepExistingSecret && creds.ClientSecret == "" {
+		if existingErr != nil || existing.ClientSecret == "" {
+			err := fmt.Errorf("Não há Client Secret salvo para o ambiente %s", env)
+			s.auditOutcome(entry, err)
+			s.sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		creds.ClientSecret = existing.ClientSecret
+	}
+
 	// Valida credenciais
 	if creds.ClientID == "" || creds.ClientSecret == "" {
-		s.sendError(w, "Client ID e Client Secret são obrigatórios", http.StatusBadRequest)
+		err := errors.New("Client ID e Client Secret são obrigatórios")
+		s.auditOutcome(entry, err)
+		s.sendError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	// Cria diretório config se não existir
 	configDir := "./config"
 	if err := os.MkdirAll(configDir, 0755); err != nil {
+		s.auditOutcome(entry, err)
 		s.sendError(w, "Erro ao criar diretório de configuração", http.StatusInternalServerError)
 		return
 	}
@@ -455,24 +858,43 @@ func (s *Server) handleSaveCredentials(w http.ResponseWriter, r *http.Request) {
 		"client_secret": creds.ClientSecret,
 		"sandbox":       env == "sandbox",
 		"env":           env,
+		"updated_at":    time.Now().UTC(),
+	}
+
+	certificatePassword := ""
+	if creds.CertificatePassword != nil {
+		certificatePassword = *creds.CertificatePassword
+	} else if existingErr == nil {
+		certificatePassword = existing.CertificatePassword
+	}
+	if certificatePassword != "" {
+		configData["certificate_password"] = certificatePassword
 	}
 
 	configBytes, err := json.MarshalIndent(configData, "", "  ")
 	if err != nil {
+		s.auditOutcome(entry, err)
 		s.sendError(w, "Erro ao serializar configuração", http.StatusInternalServerError)
 		return
 	}
 
-	if err := os.WriteFile(configPath, configBytes, 0644); err != nil {
+	if err := services.WriteSecretFile(configPath, configBytes); err != nil {
+		slog.ErrorContext(r.Context(), "erro ao gravar credenciais", "env", env, "error", err)
+		s.auditOutcome(entry, err)
 		s.sendError(w, "Erro ao salvar arquivo de configuração", http.StatusInternalServerError)
 		return
 	}
 
-	// Recarrega o serviço EFI após salvar as credenciais
-	if err := s.reloadEFIServiceWithEnv(env); err != nil {
+	entry.After = credentialsState(env)
+
+	// Descarta o serviço em cache e recarrega com as novas credenciais
+	s.registry.Invalidate(env)
+	if _, err := s.controllerFor(env); err != nil {
+		s.auditOutcome(entry, err)
 		s.sendError(w, "Erro ao recarregar serviço EFI após salvar credenciais", http.StatusInternalServerError)
 		return
 	}
+	s.auditOutcome(entry, nil)
 
 	s.sendSuccess(w, map[string]interface{}{
 		"message": fmt.Sprintf("Credenciais %s salvas com sucesso", env),
@@ -481,7 +903,46 @@ func (s *Server) handleSaveCredentials(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// handleLoadCredentials carrega as credenciais do arquivo JSON
+// storedCredentials é o conteúdo do arquivo de credenciais de um ambiente
+type storedCredentials struct {
+	ClientID            string     `json:"client_id"`
+	ClientSecret        string     `json:"client_secret"`
+	Sandbox             bool       `json:"sandbox"`
+	Env                 string     `json:"env"`
+	CertificatePassword string     `json:"certificate_password"`
+	UpdatedAt           *time.Time `json:"updated_at"`
+}
+
+// readStoredCredentials lê o arquivo de credenciais do ambiente. Arquivos
+// gravados antes de existir updated_at usam a data de modificação.
+func readStoredCredentials(env string) (*storedCredentials, error) {
+	configPath := filepath.Join("./config", fmt.Sprintf("credentials_%s.json", env))
+
+	info, err := os.Stat(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	configBytes, err := services.ReadSecretFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler arquivo de credenciais: %v", err)
+	}
+
+	var creds storedCredentials
+	if err := json.Unmarshal(configBytes, &creds); err != nil {
+		return nil, fmt.Errorf("erro ao decodificar credenciais do arquivo: %v", err)
+	}
+
+	if creds.UpdatedAt == nil {
+		modTime := info.ModTime().UTC()
+		creds.UpdatedAt = &modTime
+	}
+
+	return &creds, nil
+}
+
+// handleLoadCredentials retorna as credenciais do ambiente com o client_secret
+// mascarado. O valor completo só sai por handleRevealCredentials.
 func (s *Server) handleLoadCredentials(w http.ResponseWriter, r *http.Request) {
 	// Get environment from query parameter
 	env := r.URL.Query().Get("env")
@@ -495,40 +956,85 @@ func (s *Server) handleLoadCredentials(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	configDir := "./config"
-	configPath := filepath.Join(configDir, fmt.Sprintf("credentials_%s.json", env))
-
-	if _, err := os.Stat(configPath); os.IsNotExist(err) {
+	creds, err := readStoredCredentials(env)
+	if os.IsNotExist(err) {
 		s.sendError(w, fmt.Sprintf("Arquivo de credenciais %s não encontrado", env), http.StatusNotFound)
 		return
 	}
-
-	configBytes, err := os.ReadFile(configPath)
 	if err != nil {
+		slog.ErrorContext(r.Context(), "erro ao ler credenciais", "env", env, "error", err)
 		s.sendError(w, "Erro ao ler arquivo de credenciais", http.StatusInternalServerError)
 		return
 	}
 
-	var creds struct {
-		ClientID     string `json:"client_id"`
-		ClientSecret string `json:"client_secret"`
-		Sandbox      bool   `json:"sandbox"`
-		Env          string `json:"env"`
+	s.sendSuccess(w, map[string]interface{}{
+		"client_id":                 creds.ClientID,
+		"client_secret_masked":      services.MaskSecret(creds.ClientSecret),
+		"client_secret_fingerprint": services.SecretFingerprint(creds.ClientSecret),
+		"has_client_secret":         creds.ClientSecret != "",
+		"has_certificate_password":  creds.CertificatePassword != "",
+		"updated_at":                creds.UpdatedAt,
+		"sandbox":                   creds.Sandbox,
+		"env":                       env,
+	})
+}
+
+// handleRevealCredentials retorna o client_secret completo do ambiente. Exige
+// um usuário administrador e registra cada tentativa no log de auditoria.
+func (s *Server) handleRevealCredentials(w http.ResponseWriter, r *http.Request) {
+	env := r.URL.Query().Get("env")
+	if env == "" {
+		env = "sandbox"
 	}
 
-	if err := json.Unmarshal(configBytes, &creds); err != nil {
-		s.sendError(w, "Erro ao decodificar credenciais do arquivo", http.StatusInternalServerError)
+	if env != "sandbox" && env != "production" {
+		s.sendError(w, "Ambiente inválido. Use 'sandbox' ou 'production'", http.StatusBadRequest)
 		return
 	}
 
+	principal := principalFrom(r)
+	entry := s.auditEntry(r, env, services.AuditRevealCredentials)
+
+	if err := services.Authorize(principal.Role, services.PermCredentialsReveal, env); err != nil {
+		entry.Outcome, entry.Reason = "denied", err.Error()
+		s.recordAudit(entry)
+		s.sendErrorCode(w, entry.Reason, "forbidden", http.StatusForbidden, nil)
+		return
+	}
+
+	creds, err := readStoredCredentials(env)
+	if err != nil {
+		entry.Outcome, entry.Reason = "failed", "credenciais não encontradas"
+		s.recordAudit(entry)
+		s.sendError(w, fmt.Sprintf("Arquivo de credenciais %s não encontrado", env), http.StatusNotFound)
+		return
+	}
+
+	entry.Outcome = "success"
+	if err := s.audit.Append(entry); err != nil {
+		// Sem registro de auditoria o segredo não é revelado
+		slog.ErrorContext(r.Context(), "erro ao gravar auditoria", "error", err)
+		s.sendError(w, "Erro ao registrar auditoria", http.StatusInternalServerError)
+		return
+	}
+
+	slog.WarnContext(r.Context(), "client secret revelado", "env", env, "actor", principal.Username)
+
+	w.Header().Set("Cache-Control", "no-store")
 	s.sendSuccess(w, map[string]interface{}{
 		"client_id":     creds.ClientID,
 		"client_secret": creds.ClientSecret,
-		"sandbox":       creds.Sandbox,
 		"env":           env,
 	})
 }
 
+// recordAudit grava a entrada de auditoria, apenas registrando falhas de escrita
+func (s *Server) recordAudit(entry services.AuditEntry) {
+	if err := s.audit.Append(entry); err != nil {
+		slog.Error("erro ao gravar auditoria", "action", entry.Action, "error", err)
+	}
+}
+
 // handleCertificateStatus verifica o status do certificado
 func (s *Server) handleCertificateStatus(w http.ResponseWriter, r *http.Request) {
 	// Get environment from query parameter
@@ -543,10 +1049,9 @@ func (s *Server) handleCertificateStatus(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	certsDir := "./certs"
-	certPath := filepath.Join(certsDir, fmt.Sprintf("certificado_%s.p12", env))
+	status := services.InspectCertificate(env, time.Now())
 
-	if _, err := os.Stat(certPath); os.IsNotExist(err) {
+	if !status.Exists {
 		s.sendSuccess(w, map[string]interface{}{
 			"exists": false,
 			"path":   "",
@@ -556,9 +1061,49 @@ func (s *Server) handleCertificateStatus(w http.ResponseWriter, r *http.Request)
 	}
 
 	s.sendSuccess(w, map[string]interface{}{
-		"exists": true,
-		"path":   certPath,
-		"env":    env,
+		"exists":            true,
+		"path":              status.Path,
+		"format":            status.Format,
+		"env":               env,
+		"certificate":       status.Certificate,
+		"days_until_expiry": status.DaysUntilExpiry,
+		"expired":           status.Expired,
+		"error":             status.Error,
+	})
+}
+
+// handleCertificates retorna o inventário dos certificados de cada ambiente,
+// com os detalhes do certificado, dias até a expiração e avisos ativos
+func (s *Server) handleCertificates(w http.ResponseWriter, r *http.Request) {
+	envs := services.KnownEnvironments
+	if env := r.URL.Query().Get("env"); env != "" {
+		if env != "sandbox" && env != "production" {
+			s.sendError(w, "Ambiente inválido. Use 'sandbox' ou 'production'", http.StatusBadRequest)
+			return
+		}
+		envs = []string{env}
+	}
+
+	now := time.Now()
+	certificates := make([]services.CertificateStatus, 0, len(envs))
+	for _, env := range envs {
+		certificates = append(certificates, services.InspectCertificate(env, now))
+	}
+
+	warnings := []services.CertificateWarning{}
+	for _, warning := range s.monitor.Warnings() {
+		for _, env := range envs {
+			if warning.Env == env {
+				warnings = append(warnings, warning)
+			}
+		}
+	}
+
+	s.sendSuccess(w, map[string]interface{}{
+		"certificates": certificates,
+		"warnings":     warnings,
+		"thresholds":   s.monitor.Thresholds(),
+		"checked_at":   now,
 	})
 }
 
@@ -576,10 +1121,16 @@ func (s *Server) handleReloadService(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := s.reloadEFIServiceWithEnv(env); err != nil {
+	entry := s.auditEntry(r, env, services.AuditReloadService)
+
+	// Descarta o serviço em cache para reler credenciais e certificado
+	s.registry.Invalidate(env)
+	if _, err := s.controllerFor(env); err != nil {
+		s.auditOutcome(entry, err)
 		s.sendError(w, fmt.Sprintf("Erro ao recarregar serviço: %v", err), http.StatusInternalServerError)
 		return
 	}
+	s.auditOutcome(entry, nil)
 
 	s.sendSuccess(w, map[string]interface{}{
 		"message": fmt.Sprintf("Serviço EFI recarregado com sucesso para ambiente: %s", env),
@@ -604,3 +1155,50 @@ func (s *Server) sendError(w http.ResponseWriter, message string, status int) {
 		"error":   message,
 	})
 }
+
+// sendErrorCode envia resposta de erro com um código estável para o frontend
+func (s *Server) sendErrorCode(w http.ResponseWriter, message, code string, status int, details interface{}) {
+	body := map[string]interface{}{
+		"success": false,
+		"error":   message,
+		"code":    code,
+	}
+	if details != nil {
+		body["details"] = details
+	}
+
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// sendEFIError traduz uma falha da chamada à EFI para o status HTTP e o
+// código de erro correspondentes
+func (s *Server) sendEFIError(w http.ResponseWriter, err error) {
+	if errors.Is(err, context.DeadlineExceeded) {
+		s.sendErrorCode(w, err.Error(), "efi_timeout", http.StatusGatewayTimeout, nil)
+		return
+	}
+
+	efiErr, ok := services.AsEFIError(err)
+	if !ok {
+		s.sendErrorCode(w, err.Error(), "internal_error", http.StatusInternalServerError, nil)
+		return
+	}
+
+	status, code := http.StatusBadGateway, "efi_error"
+	switch {
+	case services.IsNotFound(err):
+		status, code = http.StatusNotFound, "efi_not_found"
+	case services.IsValidation(err):
+		status, code = http.StatusBadRequest, "efi_validation"
+	case services.IsRateLimited(err):
+		status, code = http.StatusTooManyRequests, "efi_rate_limited"
+	case services.IsUnauthorized(err):
+		// Credenciais ou certificado recusados pela EFI: falha do upstream, não do usuário
+		status, code = http.StatusBadGateway, "efi_unauthorized"
+	case services.IsServerError(err):
+		status, code = http.StatusBadGateway, "efi_unavailable"
+	}
+
+	s.sendErrorCode(w, err.Error(), code, status, efiErr)
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"pix_cli/controllers"
+	"pix_cli/logging"
+	"pix_cli/models"
+	"pix_cli/services"
+)
+
+// auditEntry inicia a entrada de auditoria de uma operação da requisição
+func (s *Server) auditEntry(r *http.Request, env, action string) services.AuditEntry {
+	return services.AuditEntry{
+		Actor:      principalFrom(r).Username,
+		Env:        env,
+		Action:     action,
+		RemoteAddr: r.RemoteAddr,
+		RequestID:  logging.RequestID(r.Context()),
+	}
+}
+
+// auditOutcome completa a entrada com o resultado da operação e a grava
+func (s *Server) auditOutcome(entry services.AuditEntry, err error) {
+	entry.Outcome = "success"
+	if err != nil {
+		entry.Outcome, entry.Reason = "failed", err.Error()
+	}
+	s.recordAudit(entry)
+}
+
+// efiStatus extrai o status HTTP devolvido pela EFI, com sucesso ou erro
+func efiStatus(response *models.WebhookResponse, err error) int {
+	var efiErr *services.EFIError
+	if errors.As(err, &efiErr) {
+		return efiErr.StatusCode
+	}
+	if response != nil {
+		return response.Code
+	}
+	return 0
+}
+
+// webhookState consulta na EFI o webhook configurado, para registrar o valor
+// anterior a uma alteração. Retorna nil se a consulta falhar.
+func webhookState(ctx context.Context, controller *controllers.WebhookController, webhookType models.WebhookType) map[string]interface{} {
+	response, err := controller.GetEFIService().ListWebhookContext(ctx, webhookType)
+	if services.IsNotFound(err) {
+		return map[string]interface{}{"type": string(webhookType), "configured": false}
+	}
+	if err != nil {
+		return nil
+	}
+
+	return map[string]interface{}{
+		"type":       string(webhookType),
+		"configured": true,
+		"webhookUrl": response.Data["webhookUrl"],
+	}
+}
+
+// credentialsState descreve as credenciais salvas do ambiente sem os segredos
+func credentialsState(env string) map[string]interface{} {
+	creds, err := readStoredCredentials(env)
+	if err != nil {
+		return nil
+	}
+
+	return map[string]interface{}{
+		"client_id":                 creds.ClientID,
+		"client_secret_fingerprint": services.SecretFingerprint(creds.ClientSecret),
+		"has_certificate_password":  creds.CertificatePassword != "",
+		"updated_at":                creds.UpdatedAt,
+	}
+}
+
+// certificateState descreve o certificado instalado no ambiente
+func certificateState(env string) map[string]interface{} {
+	status := services.InspectCertificate(env, time.Now())
+	if !status.Exists {
+		return nil
+	}
+
+	state := map[string]interface{}{
+		"format": status.Format,
+		"path":   status.Path,
+	}
+	if status.Certificate != nil {
+		state["subject"] = status.Certificate.Subject
+		state["serial"] = status.Certificate.SerialNumber
+		state["sha256_fingerprint"] = status.Certificate.Fingerprint
+		state["not_after"] = status.Certificate.NotAfter
+	}
+	return state
+}
+
+// queryTimeRange lê os parâmetros since e until (RFC 3339) das consultas
+func queryTimeRange(query url.Values) (since, until time.Time, err error) {
+	for name, target := range map[string]*time.Time{"since": &since, "until": &until} {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+
+		if *target, err = time.Parse(time.RFC3339, value); err != nil {
+			return since, until, fmt.Errorf("Parâmetro '%s' inválido (use RFC 3339, ex: 2025-01-31T00:00:00Z)", name)
+		}
+	}
+	return since, until, nil
+}
+
+// queryLimit lê o parâmetro limit das consultas, entre 1 e 1000
+func queryLimit(query url.Values, fallback int) (int, error) {
+	value := query.Get("limit")
+	if value == "" {
+		return fallback, nil
+	}
+
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit <= 0 || limit > 1000 {
+		return 0, fmt.Errorf("Parâmetro 'limit' deve estar entre 1 e 1000")
+	}
+	return limit, nil
+}
+
+// handleAudit consulta o log de auditoria. Filtros opcionais: env, action,
+// since e until (RFC 3339) e limit (padrão 100, máximo 1000).
+func (s *Server) handleAudit(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := services.AuditFilter{
+		Env:    query.Get("env"),
+		Action: query.Get("action"),
+		Limit:  100,
+	}
+
+	if filter.Env != "" && filter.Env != "sandbox" && filter.Env != "production" {
+		s.sendError(w, "Ambiente inválido. Use 'sandbox' ou 'production'", http.StatusBadRequest)
+		return
+	}
+
+	var err error
+	if filter.Since, filter.Until, err = queryTimeRange(query); err != nil {
+		s.sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if filter.Limit, err = queryLimit(query, 100); err != nil {
+		s.sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	entries, err := s.audit.Query(filter)
+	if err != nil {
+		s.sendError(w, "Erro ao ler log de auditoria", http.StatusInternalServerError)
+		return
+	}
+
+	s.sendSuccess(w, map[string]interface{}{
+		"entries": entries,
+		"count":   len(entries),
+	})
+}
+
+// recordWebhookChange acrescenta ao histórico uma alteração de webhook
+// concluída na EFI, apenas registrando falhas de escrita
+func (s *Server) recordWebhookChange(r *http.Request, env, webhookType, action, webhookURL string) {
+	err := s.history.Record(services.WebhookChange{
+		Env:        env,
+		Type:       webhookType,
+		Action:     action,
+		WebhookURL: webhookURL,
+		Actor:      principalFrom(r).Username,
+		RequestID:  logging.RequestID(r.Context()),
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "erro ao gravar histórico de webhooks", "env", env, "error", err)
+	}
+}
+
+// handleWebhookHistory lista o histórico de configuração dos webhooks.
+// Filtros opcionais: env, type e limit (padrão 50, máximo 1000).
+func (s *Server) handleWebhookHistory(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	env := query.Get("env")
+	if env != "" && env != "sandbox" && env != "production" {
+		s.sendError(w, "Ambiente inválido. Use 'sandbox' ou 'production'", http.StatusBadRequest)
+		return
+	}
+
+	limit, err := queryLimit(query, 50)
+	if err != nil {
+		s.sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	changes, err := s.history.List(env, query.Get("type"), limit)
+	if err != nil {
+		s.sendError(w, "Erro ao ler histórico de webhooks", http.StatusInternalServerError)
+		return
+	}
+
+	s.sendSuccess(w, map[string]interface{}{
+		"changes": changes,
+		"count":   len(changes),
+	})
+}
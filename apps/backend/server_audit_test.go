@@ -0,0 +1,89 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"testing"
+
+	"pix_cli/services"
+)
+
+// lastAudit retorna a entrada mais recente da ação no ambiente
+func (ts *testServer) lastAudit(t *testing.T, env, action string) services.AuditEntry {
+	t.Helper()
+
+	entries, err := ts.audit.Query(services.AuditFilter{Env: env, Action: action, Limit: 1})
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if len(entries) == 0 {
+		t.Fatalf("nenhuma entrada de auditoria %s em %s", action, env)
+	}
+	return entries[0]
+}
+
+// Recusas depois de iniciada a entrada de auditoria também são registradas
+func TestSaveCredentialsFailuresAreAudited(t *testing.T) {
+	ts := newTestServer(t)
+	token := ts.login(t, "admin", "admin")
+
+	cases := []struct {
+		name   string
+		body   map[string]interface{}
+		reason string
+	}{
+		{"sem client secret", map[string]interface{}{"env": "sandbox", "clientId": "x"}, "Client ID e Client Secret são obrigatórios"},
+		{"sem segredo salvo", map[string]interface{}{"env": "sandbox", "clientId": "x", "keepExistingSecret": true}, "Não há Client Secret salvo para o ambiente sandbox"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if status, resp := ts.do(t, token, http.MethodPost, "/api/save-credentials", tc.body); status != http.StatusBadRequest {
+				t.Fatalf("status = %d (%s), esperado 400", status, resp.Error)
+			}
+
+			entry := ts.lastAudit(t, "sandbox", services.AuditSaveCredentials)
+			if entry.Outcome != "failed" || entry.Reason != tc.reason {
+				t.Errorf("auditoria = %s (%s), esperado failed (%s)", entry.Outcome, entry.Reason, tc.reason)
+			}
+		})
+	}
+
+	// Diretório de configuração impossível de criar
+	if err := os.WriteFile("config", nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	status, resp := ts.do(t, token, http.MethodPost, "/api/save-credentials", map[string]interface{}{
+		"env": "sandbox", "clientId": "x", "clientSecret": "y",
+	})
+	if status != http.StatusInternalServerError {
+		t.Fatalf("status = %d (%s), esperado 500", status, resp.Error)
+	}
+	if entry := ts.lastAudit(t, "sandbox", services.AuditSaveCredentials); entry.Outcome != "failed" {
+		t.Errorf("auditoria = %s, esperado failed", entry.Outcome)
+	}
+}
+
+func TestCertificateDirectoryFailureIsAudited(t *testing.T) {
+	// Sem CA configurada, para que o arquivo certs não afete a leitura da CA
+	t.Setenv("EFI_CLIENT_CA_FILE", "./inexistente/ca.pem")
+	t.Setenv("EFI_CLIENT_CA_FILE_SANDBOX", "")
+	t.Setenv("EFI_CERT_ISSUERS", "")
+	t.Setenv("EFI_CERT_ISSUERS_SANDBOX", "")
+
+	ts := newTestServer(t)
+	token := ts.login(t, "admin", "admin")
+
+	if err := os.WriteFile("certs", nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	certPEM, keyPEM := certificatePEMPair(t, "Efí Pay - Homologação")
+	if status, resp := ts.uploadPEM(t, token, "sandbox", certPEM, keyPEM); status != http.StatusInternalServerError {
+		t.Fatalf("status = %d (%s), esperado 500", status, resp.Error)
+	}
+
+	if entry := ts.lastAudit(t, "sandbox", services.AuditUploadCertificate); entry.Outcome != "failed" {
+		t.Errorf("auditoria = %s (%s), esperado failed", entry.Outcome, entry.Reason)
+	}
+}
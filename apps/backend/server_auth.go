@@ -0,0 +1,397 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"pix_cli/services"
+)
+
+type principalKey struct{}
+
+// sessionCookie guarda o token de sessão no navegador (HttpOnly), enviado em
+// requisições com credenciais liberadas pela política de CORS
+const sessionCookie = "pix_session"
+
+// principalFrom retorna quem fez a requisição, preenchido por requireAuth
+func principalFrom(r *http.Request) services.Principal {
+	principal, ok := r.Context().Value(principalKey{}).(services.Principal)
+	if !ok {
+		return services.Principal{Username: "anonymous"}
+	}
+	return principal
+}
+
+// bearerToken extrai o token do cabeçalho Authorization: Bearer <token>
+func bearerToken(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	if len(header) < 7 || !strings.EqualFold(header[:7], "Bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(header[7:])
+}
+
+// sessionToken retorna o token enviado em Authorization ou, na falta dele,
+// no cookie de sessão
+func sessionToken(r *http.Request) string {
+	if token := bearerToken(r); token != "" {
+		return token
+	}
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
+
+// setSessionCookie grava (ou, com token vazio, apaga) o cookie de sessão
+func setSessionCookie(w http.ResponseWriter, r *http.Request, token string, expires time.Time) {
+	cookie := &http.Cookie{
+		Name:     sessionCookie,
+		Value:    token,
+		Path:     "/api/",
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	}
+	if token == "" {
+		cookie.MaxAge = -1
+	} else {
+		cookie.Expires = expires
+	}
+	http.SetCookie(w, cookie)
+}
+
+// authenticate identifica o autor da requisição pelo token estático da API
+// (PIX_API_TOKEN) ou por um token de sessão obtido em /api/auth/login, enviado
+// em Authorization ou no cookie de sessão
+func (s *Server) authenticate(r *http.Request) (services.Principal, bool) {
+	if s.authDisabled {
+		return services.Principal{Username: "anonymous", Role: services.RoleAdmin, Method: "disabled"}, true
+	}
+
+	if services.MatchAPIToken(bearerToken(r), s.apiToken) {
+		return services.Principal{Username: "api-token", Role: services.RoleAdmin, Method: "api_token"}, true
+	}
+
+	token := sessionToken(r)
+	if token == "" {
+		return services.Principal{}, false
+	}
+
+	if session, ok := s.sessions.Lookup(token); ok {
+		// O papel vem do cadastro atual, para que alterações valham sem novo login
+		user, exists := s.users.Get(session.Principal.Username)
+		if !exists {
+			s.sessions.Revoke(token)
+			return services.Principal{}, false
+		}
+
+		principal := session.Principal
+		principal.Role = user.Role
+		return principal, true
+	}
+
+	return services.Principal{}, false
+}
+
+// requireAuth exige autenticação em todas as rotas da API, exceto o login
+func (s *Server) requireAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/auth/login" {
+			next(w, r)
+			return
+		}
+
+		principal, ok := s.authenticate(r)
+		if !ok {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("WWW-Authenticate", `Bearer realm="pix-auto-webhook"`)
+			s.sendErrorCode(w, "Autenticação necessária", "unauthenticated", http.StatusUnauthorized, nil)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), principalKey{}, principal)
+		next(w, r.WithContext(ctx))
+	}
+}
+
+// handleLogin autentica usuário e senha locais e abre uma sessão
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.sendError(w, "Erro ao decodificar JSON", http.StatusBadRequest)
+		return
+	}
+
+	user, err := s.users.Authenticate(req.Username, req.Password)
+	if err != nil {
+		if !errors.Is(err, services.ErrInvalidLogin) {
+			slog.ErrorContext(r.Context(), "erro ao autenticar usuário", "error", err)
+		}
+		slog.WarnContext(r.Context(), "login recusado", "username", req.Username, "remote_addr", r.RemoteAddr)
+		s.sendErrorCode(w, err.Error(), "invalid_login", http.StatusUnauthorized, nil)
+		return
+	}
+
+	token, session, err := s.sessions.Create(services.Principal{
+		Username: user.Username,
+		Role:     user.Role,
+		Method:   "session",
+	})
+	if err != nil {
+		s.sendError(w, "Erro ao criar sessão", http.StatusInternalServerError)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "login realizado", "username", user.Username)
+
+	setSessionCookie(w, r, token, session.ExpiresAt)
+	w.Header().Set("Cache-Control", "no-store")
+	s.sendSuccess(w, map[string]interface{}{
+		"token":      token,
+		"expires_at": session.ExpiresAt,
+		"user":       session.Principal,
+	})
+}
+
+// handleLogout encerra a sessão do token enviado
+func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if token := sessionToken(r); token != "" {
+		s.sessions.Revoke(token)
+	}
+	setSessionCookie(w, r, "", time.Time{})
+
+	s.sendSuccess(w, map[string]interface{}{
+		"message": "Sessão encerrada",
+	})
+}
+
+// handleMe retorna o usuário autenticado
+func (s *Server) handleMe(w http.ResponseWriter, r *http.Request) {
+	s.sendSuccess(w, principalFrom(r))
+}
+
+// routePermissions define a permissão exigida por rota ("MÉTODO caminho").
+// Rotas ausentes exigem apenas autenticação; reveal-credentials faz a própria
+// verificação para registrar recusas na auditoria.
+var routePermissions = map[string]services.Permission{
+	"POST /api/webhook/config":         services.PermWebhookWrite,
+	"GET /api/webhook/list":            services.PermWebhookRead,
+	"DELETE /api/webhook/delete":       services.PermWebhookWrite,
+	"POST /api/webhook/rotate-secret":  services.PermWebhookWrite,
+	"GET /api/webhook/history":         services.PermWebhookRead,
+	"GET /api/events":                  services.PermWebhookRead,
+	"GET /api/events/stats":            services.PermWebhookRead,
+	"GET /api/test-connection":         services.PermWebhookRead,
+	"POST /api/upload-certificate":     services.PermCertificateWrite,
+	"POST /api/upload-certificate-pem": services.PermCertificateWrite,
+	"POST /api/save-credentials":       services.PermCredentialsWrite,
+	"GET /api/load-credentials":        services.PermCredentialsRead,
+	"GET /api/certificate-status":      services.PermCertificateRead,
+	"GET /api/certificates":            services.PermCertificateRead,
+	"POST /api/reload-service":         services.PermServiceReload,
+	"GET /api/users":                   services.PermUsersManage,
+	"POST /api/users":                  services.PermUsersManage,
+	"PUT /api/users/role":              services.PermUsersManage,
+	"DELETE /api/users":                services.PermUsersManage,
+	"GET /api/audit":                   services.PermAuditRead,
+}
+
+// globalPermissions não dependem do ambiente da requisição
+var globalPermissions = map[services.Permission]bool{
+	services.PermUsersManage: true,
+}
+
+// maxAPIBody limita o corpo JSON das requisições da API, lido por requestEnv
+// antes do handler
+const maxAPIBody = 1 << 20
+
+// errEnvMismatch indica ambientes diferentes na query e no corpo
+var errEnvMismatch = errors.New("Ambiente divergente")
+
+// requestEnv descobre o ambiente alvo da requisição pela query (?env=) e, em
+// requisições JSON, pelo campo "env" do corpo, que é restaurado para o handler.
+// Os handlers de escrita leem o ambiente do corpo e os de consulta, da query;
+// se os dois forem informados e divergirem, a requisição é recusada, para que
+// a permissão seja sempre conferida no mesmo ambiente em que o handler atua.
+// Sem ambiente informado, os handlers usam sandbox; as consultas de
+// inventário, auditoria, histórico e eventos sem ?env= abrangem todos os
+// ambientes. Corpos acima de maxAPIBody são recusados com *http.MaxBytesError,
+// em vez de chegarem truncados ao handler.
+func requestEnv(w http.ResponseWriter, r *http.Request) ([]string, error) {
+	queryEnv := r.URL.Query().Get("env")
+
+	var bodyEnv string
+	// Uploads multipart informam o ambiente sempre pela query
+	if r.Method != http.MethodGet && r.Body != nil && !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxAPIBody))
+		if err != nil {
+			return nil, fmt.Errorf("erro ao ler requisição: %w", err)
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		var payload struct {
+			Env string `json:"env"`
+		}
+		if json.Unmarshal(body, &payload) == nil {
+			bodyEnv = payload.Env
+		}
+	}
+
+	switch {
+	case queryEnv != "" && bodyEnv != "" && queryEnv != bodyEnv:
+		return nil, fmt.Errorf("%w: '%s' na query e '%s' no corpo", errEnvMismatch, queryEnv, bodyEnv)
+	case queryEnv != "":
+		return []string{queryEnv}, nil
+	case bodyEnv != "":
+		return []string{bodyEnv}, nil
+	}
+
+	switch r.URL.Path {
+	case "/api/certificates", "/api/audit", "/api/webhook/history", "/api/events", "/api/events/stats":
+		return services.KnownEnvironments, nil
+	}
+	return []string{"sandbox"}, nil
+}
+
+// authorize confere a permissão do usuário para a rota no ambiente da
+// requisição, respondendo 403 com o motivo em caso de recusa
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request, perm services.Permission) bool {
+	principal := principalFrom(r)
+
+	envs := []string{""}
+	if !globalPermissions[perm] {
+		var err error
+		if envs, err = requestEnv(w, r); err != nil {
+			var tooLarge *http.MaxBytesError
+			switch {
+			case errors.As(err, &tooLarge):
+				s.sendErrorCode(w, fmt.Sprintf("Requisição muito grande (máximo de %d bytes)", maxAPIBody), "request_too_large", http.StatusRequestEntityTooLarge, nil)
+			case errors.Is(err, errEnvMismatch):
+				s.sendErrorCode(w, err.Error(), "env_mismatch", http.StatusBadRequest, nil)
+			default:
+				s.sendErrorCode(w, err.Error(), "invalid_request", http.StatusBadRequest, nil)
+			}
+			return false
+		}
+	}
+
+	for _, env := range envs {
+		if err := services.Authorize(principal.Role, perm, env); err != nil {
+			slog.WarnContext(r.Context(), "acesso negado", "username", principal.Username, "role", principal.Role, "permission", perm, "env", env)
+			s.sendErrorCode(w, err.Error(), "forbidden", http.StatusForbidden, map[string]interface{}{
+				"role":       principal.Role,
+				"permission": perm,
+				"env":        env,
+			})
+			return false
+		}
+	}
+
+	return true
+}
+
+// handleListRoles lista os papéis disponíveis
+func (s *Server) handleListRoles(w http.ResponseWriter, r *http.Request) {
+	s.sendSuccess(w, map[string]interface{}{
+		"roles": services.Roles(),
+	})
+}
+
+// userView é o usuário sem o hash da senha
+func userView(user services.User) map[string]interface{} {
+	return map[string]interface{}{
+		"username":   user.Username,
+		"role":       user.Role,
+		"created_at": user.CreatedAt,
+	}
+}
+
+// handleListUsers lista os usuários locais
+func (s *Server) handleListUsers(w http.ResponseWriter, r *http.Request) {
+	users := []map[string]interface{}{}
+	for _, user := range s.users.List() {
+		users = append(users, userView(user))
+	}
+
+	s.sendSuccess(w, map[string]interface{}{
+		"users": users,
+	})
+}
+
+// handleCreateUser cadastra um usuário com o papel informado
+func (s *Server) handleCreateUser(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+		Role     string `json:"role"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.sendError(w, "Erro ao decodificar JSON", http.StatusBadRequest)
+		return
+	}
+
+	user, err := s.users.Create(req.Username, req.Password, req.Role)
+	if err != nil {
+		s.sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "usuário criado", "username", user.Username, "role", user.Role, "by", principalFrom(r).Username)
+	s.sendSuccess(w, userView(user))
+}
+
+// handleSetUserRole altera o papel de um usuário
+func (s *Server) handleSetUserRole(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Username string `json:"username"`
+		Role     string `json:"role"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.sendError(w, "Erro ao decodificar JSON", http.StatusBadRequest)
+		return
+	}
+
+	user, err := s.users.SetRole(req.Username, req.Role)
+	if err != nil {
+		s.sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "papel alterado", "username", user.Username, "role", user.Role, "by", principalFrom(r).Username)
+	s.sendSuccess(w, userView(user))
+}
+
+// handleDeleteUser remove um usuário e encerra as sessões dele
+func (s *Server) handleDeleteUser(w http.ResponseWriter, r *http.Request) {
+	username := r.URL.Query().Get("username")
+	if username == "" {
+		s.sendError(w, "Parâmetro 'username' é obrigatório", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.users.Delete(username); err != nil {
+		s.sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.sessions.RevokeUser(username)
+
+	slog.InfoContext(r.Context(), "usuário removido", "username", username, "by", principalFrom(r).Username)
+	s.sendSuccess(w, map[string]interface{}{
+		"message": fmt.Sprintf("Usuário %s removido", username),
+	})
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"pix_cli/models"
+)
+
+// Um developer só escreve em sandbox: o ambiente da query não pode liberar a
+// permissão enquanto o handler atua no ambiente do corpo
+func TestRequestEnvMismatchIsRejected(t *testing.T) {
+	ts := newTestServer(t)
+	token := ts.login(t, "dev", "developer")
+
+	cases := []struct {
+		name   string
+		method string
+		target string
+		body   map[string]interface{}
+	}{
+		{"save-credentials", http.MethodPost, "/api/save-credentials?env=sandbox", map[string]interface{}{"env": "production", "clientId": "x", "clientSecret": "y"}},
+		{"webhook config", http.MethodPost, "/api/webhook/config?env=sandbox", map[string]interface{}{"env": "production", "type": "charge", "url": "https://evil.example/webhook"}},
+		{"webhook delete", http.MethodDelete, "/api/webhook/delete?env=sandbox", map[string]interface{}{"env": "production", "type": "charge"}},
+		{"rotate-secret", http.MethodPost, "/api/webhook/rotate-secret?env=sandbox", map[string]interface{}{"env": "production", "type": "charge"}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			status, resp := ts.do(t, token, tc.method, tc.target, tc.body)
+			if status != http.StatusBadRequest || resp.Code != "env_mismatch" {
+				t.Fatalf("status = %d, code = %q; esperado 400 env_mismatch (%s)", status, resp.Code, resp.Error)
+			}
+		})
+	}
+
+	if _, err := os.Stat("config/credentials_production.json"); !os.IsNotExist(err) {
+		t.Fatalf("credenciais de produção foram gravadas (stat: %v)", err)
+	}
+	if _, err := ts.fake("production").ListWebhookContext(context.Background(), models.WebhookTypeCharge); err == nil {
+		t.Fatalf("webhook de produção foi configurado")
+	}
+}
+
+func TestDeveloperCannotWriteProductionFromBody(t *testing.T) {
+	ts := newTestServer(t)
+	token := ts.login(t, "dev", "developer")
+
+	status, resp := ts.do(t, token, http.MethodPost, "/api/webhook/config", map[string]interface{}{
+		"env": "production", "type": "charge", "url": "https://example.com/webhook",
+	})
+	if status != http.StatusForbidden || resp.Code != "forbidden" {
+		t.Fatalf("status = %d, code = %q; esperado 403 forbidden", status, resp.Code)
+	}
+
+	status, resp = ts.do(t, token, http.MethodPost, "/api/webhook/config?env=sandbox", map[string]interface{}{
+		"env": "sandbox", "type": "charge", "url": "https://example.com/webhook",
+	})
+	if status != http.StatusOK {
+		t.Fatalf("status = %d (%s); esperado 200 em sandbox", status, resp.Error)
+	}
+}
+
+// Corpo acima do limite é recusado antes do handler, em vez de chegar truncado
+func TestOversizedBodyIsRejected(t *testing.T) {
+	ts := newTestServer(t)
+	token := ts.login(t, "admin", "admin")
+
+	status, resp := ts.do(t, token, http.MethodPost, "/api/webhook/config", map[string]interface{}{
+		"env": "sandbox", "type": "charge", "url": "https://example.com/webhook",
+		"padding": strings.Repeat("x", maxAPIBody),
+	})
+	if status != http.StatusRequestEntityTooLarge || resp.Code != "request_too_large" {
+		t.Fatalf("status = %d, code = %q; esperado 413 request_too_large (%s)", status, resp.Code, resp.Error)
+	}
+	if _, err := ts.fake("sandbox").ListWebhookContext(context.Background(), models.WebhookTypeCharge); err == nil {
+		t.Fatalf("webhook configurado com corpo acima do limite")
+	}
+}
+
+// Recarregar o serviço não é consulta: viewer e developer não recarregam produção
+func TestReadOnlyRolesCannotReloadProduction(t *testing.T) {
+	ts := newTestServer(t)
+
+	for _, role := range []string{"viewer", "developer"} {
+		token := ts.login(t, role, role)
+
+		status, resp := ts.do(t, token, http.MethodPost, "/api/reload-service?env=production", nil)
+		if status != http.StatusForbidden || resp.Code != "forbidden" {
+			t.Fatalf("%s: status = %d, code = %q; esperado 403 forbidden", role, status, resp.Code)
+		}
+	}
+
+	token := ts.login(t, "ops", "operator")
+	if status, resp := ts.do(t, token, http.MethodPost, "/api/reload-service?env=production", nil); status != http.StatusOK {
+		t.Fatalf("operator: status = %d (%s); esperado 200", status, resp.Error)
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+// certificatePEMPair gera um par PEM de cliente emitido por uma CA com o nome
+// informado
+func certificatePEMPair(t *testing.T, issuer string) (certPEM, keyPEM []byte) {
+	t.Helper()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: issuer},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caCert, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "pix-auto-webhook"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, caCert, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM
+}
+
+// uploadPEM envia um par PEM para /api/upload-certificate-pem
+func (ts *testServer) uploadPEM(t *testing.T, token, env string, certPEM, keyPEM []byte) (int, apiResponse) {
+	t.Helper()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for field, data := range map[string][]byte{"certificate": certPEM, "key": keyPEM} {
+		part, err := form.CreateFormFile(field, field+".pem")
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write(data)
+	}
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/upload-certificate-pem?env="+env, &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	rec := httptest.NewRecorder()
+	ts.handler.ServeHTTP(rec, req)
+
+	var resp apiResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("resposta inválida (%d): %s", rec.Code, rec.Body)
+	}
+	return rec.Code, resp
+}
+
+func TestUploadCertificateWithoutConfiguredCA(t *testing.T) {
+	for _, name := range []string{"EFI_CLIENT_CA_FILE", "EFI_CLIENT_CA_FILE_SANDBOX", "EFI_CLIENT_CA_FILE_PRODUCTION", "EFI_CERT_ISSUERS", "EFI_CERT_ISSUERS_SANDBOX", "EFI_CERT_ISSUERS_PRODUCTION"} {
+		t.Setenv(name, "")
+	}
+
+	ts := newTestServer(t)
+	token := ts.login(t, "admin", "admin")
+
+	certPEM, keyPEM := certificatePEMPair(t, "Efí Pay - Homologação")
+	status, resp := ts.uploadPEM(t, token, "sandbox", certPEM, keyPEM)
+	if status != http.StatusOK {
+		t.Fatalf("status = %d (%s), esperado 200 sem CA configurada", status, resp.Error)
+	}
+	if _, err := os.Stat("certs/certificado_sandbox.pem"); err != nil {
+		t.Errorf("certificado não instalado: %v", err)
+	}
+
+	// Sem CA, o emissor de homologação continua recusado em produção
+	status, resp = ts.uploadPEM(t, token, "production", certPEM, keyPEM)
+	if status != http.StatusBadRequest || resp.Code != "certificate_invalid" {
+		t.Errorf("produção: status = %d, code = %q; esperado 400 certificate_invalid", status, resp.Code)
+	}
+
+	certPEM, keyPEM = certificatePEMPair(t, "Outra CA")
+	status, resp = ts.uploadPEM(t, token, "sandbox", certPEM, keyPEM)
+	if status != http.StatusBadRequest || resp.Code != "certificate_invalid" {
+		t.Errorf("outro emissor: status = %d, code = %q; esperado 400 certificate_invalid", status, resp.Code)
+	}
+}
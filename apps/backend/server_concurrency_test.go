@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"pix_cli/models"
+)
+
+// Requisições simultâneas de sandbox e produção precisam atuar sempre no
+// serviço do próprio ambiente. Rode com go test -race.
+func TestConcurrentMixedEnvRequests(t *testing.T) {
+	ts := newTestServer(t)
+	token := ts.login(t, "admin", "admin")
+
+	const workers = 16
+	const rounds = 20
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*rounds)
+
+	for worker := 0; worker < workers; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+
+			env := []string{"sandbox", "production"}[worker%2]
+			webhookType := []string{"charge", "recurrence"}[(worker/2)%2]
+
+			for round := 0; round < rounds; round++ {
+				url := fmt.Sprintf("https://%s.example/webhook/%d/%d", env, worker, round)
+
+				status, resp, err := ts.request(token, http.MethodPost, "/api/webhook/config", map[string]interface{}{
+					"env": env, "type": webhookType, "url": url,
+				})
+				if err != nil {
+					errs <- err
+					return
+				}
+				if status != http.StatusOK {
+					errs <- fmt.Errorf("config %s/%s: status %d (%s)", env, webhookType, status, resp.Error)
+					return
+				}
+
+				status, resp, err = ts.request(token, http.MethodGet, "/api/webhook/list?env="+env+"&type="+webhookType, nil)
+				if err != nil {
+					errs <- err
+					return
+				}
+				if status != http.StatusOK {
+					errs <- fmt.Errorf("list %s/%s: status %d (%s)", env, webhookType, status, resp.Error)
+					return
+				}
+				if got, _ := resp.Data["webhookUrl"].(string); !strings.HasPrefix(got, "https://"+env+".example/") {
+					errs <- fmt.Errorf("list %s/%s retornou URL de outro ambiente: %s", env, webhookType, got)
+					return
+				}
+
+				// Recarregar o serviço no meio das requisições não pode
+				// misturar os ambientes
+				if round%5 == 0 {
+					status, resp, err := ts.request(token, http.MethodPost, "/api/reload-service?env="+env, nil)
+					if err != nil || status != http.StatusOK {
+						errs <- fmt.Errorf("reload %s: status %d (%s) %v", env, status, resp.Error, err)
+						return
+					}
+				}
+			}
+		}(worker)
+	}
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	for _, env := range []string{"sandbox", "production"} {
+		for _, webhookType := range []models.WebhookType{models.WebhookTypeCharge, models.WebhookTypeRecurrence} {
+			response, err := ts.fake(env).ListWebhookContext(context.Background(), webhookType)
+			if err != nil {
+				t.Fatalf("%s/%s: %v", env, webhookType, err)
+			}
+			if url, _ := response.Data["webhookUrl"].(string); !strings.HasPrefix(url, "https://"+env+".example/") {
+				t.Errorf("%s/%s ficou com a URL de outro ambiente: %s", env, webhookType, url)
+			}
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Valores padrão da política de CORS: apenas o frontend local
+var (
+	defaultCORSOrigins = []string{"http://localhost:3000", "http://127.0.0.1:3000"}
+	defaultCORSMethods = []string{"GET", "POST", "PUT", "DELETE"}
+	defaultCORSHeaders = []string{"Content-Type", "Authorization", "X-Request-ID"}
+)
+
+// corsPolicy define quais origens, métodos e cabeçalhos o navegador pode usar
+// em requisições cross-origin para a API
+type corsPolicy struct {
+	origins map[string]bool
+	// anyOrigin libera qualquer origem ("*"); nesse caso não há credenciais
+	anyOrigin   bool
+	methods     []string
+	headers     []string
+	credentials bool
+	maxAge      int
+}
+
+// loadCORSPolicy lê a política das variáveis de ambiente:
+//   - PIX_CORS_ORIGINS: origens separadas por vírgula (padrão: frontend local)
+//   - PIX_CORS_METHODS: métodos permitidos (padrão: GET, POST, PUT, DELETE)
+//   - PIX_CORS_HEADERS: cabeçalhos permitidos (padrão: Content-Type, Authorization, X-Request-ID)
+//   - PIX_CORS_CREDENTIALS: "false" desativa cookies e credenciais (padrão: true)
+//   - PIX_CORS_MAX_AGE: segundos de cache do preflight (padrão: 600)
+func loadCORSPolicy() (*corsPolicy, error) {
+	policy := &corsPolicy{
+		origins:     make(map[string]bool),
+		methods:     envList("PIX_CORS_METHODS", defaultCORSMethods),
+		headers:     envList("PIX_CORS_HEADERS", defaultCORSHeaders),
+		credentials: os.Getenv("PIX_CORS_CREDENTIALS") != "false",
+		maxAge:      600,
+	}
+
+	for _, origin := range envList("PIX_CORS_ORIGINS", defaultCORSOrigins) {
+		if origin == "*" {
+			policy.anyOrigin = true
+			continue
+		}
+
+		normalized, err := normalizeOrigin(origin)
+		if err != nil {
+			return nil, fmt.Errorf("PIX_CORS_ORIGINS: %v", err)
+		}
+		policy.origins[normalized] = true
+	}
+
+	if policy.anyOrigin && policy.credentials {
+		return nil, fmt.Errorf("PIX_CORS_ORIGINS=* exige PIX_CORS_CREDENTIALS=false")
+	}
+
+	for i, method := range policy.methods {
+		policy.methods[i] = strings.ToUpper(method)
+	}
+
+	if value := os.Getenv("PIX_CORS_MAX_AGE"); value != "" {
+		maxAge, err := strconv.Atoi(value)
+		if err != nil || maxAge < 0 {
+			return nil, fmt.Errorf("PIX_CORS_MAX_AGE inválido: %s", value)
+		}
+		policy.maxAge = maxAge
+	}
+
+	return policy, nil
+}
+
+// envList lê uma lista separada por vírgulas, usando o padrão se vazia
+func envList(name string, fallback []string) []string {
+	value := os.Getenv(name)
+	if strings.TrimSpace(value) == "" {
+		return append([]string(nil), fallback...)
+	}
+
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// normalizeOrigin valida uma origem (esquema://host[:porta]) e a devolve em
+// minúsculas, sem barra final
+func normalizeOrigin(origin string) (string, error) {
+	u, err := url.Parse(strings.TrimSuffix(origin, "/"))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "" || u.RawQuery != "" {
+		return "", fmt.Errorf("origem inválida: %s", origin)
+	}
+	return strings.ToLower(u.Scheme + "://" + u.Host), nil
+}
+
+// allowOrigin indica se a origem pode acessar a API
+func (p *corsPolicy) allowOrigin(origin string) bool {
+	if p.anyOrigin {
+		return true
+	}
+	return p.origins[strings.ToLower(origin)]
+}
+
+func (p *corsPolicy) allowMethod(method string) bool {
+	for _, allowed := range p.methods {
+		if allowed == method {
+			return true
+		}
+	}
+	return false
+}
+
+// allowHeaders confere os cabeçalhos pedidos em Access-Control-Request-Headers
+func (p *corsPolicy) allowHeaders(requested string) bool {
+	for _, header := range strings.Split(requested, ",") {
+		header = strings.TrimSpace(header)
+		if header == "" {
+			continue
+		}
+
+		allowed := false
+		for _, h := range p.headers {
+			if strings.EqualFold(h, header) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+	return true
+}
+
+// setOriginHeaders libera a origem na resposta. Com a lista de origens a
+// resposta varia por Origin, então caches compartilhados precisam de Vary.
+func (p *corsPolicy) setOriginHeaders(w http.ResponseWriter, origin string) {
+	if p.anyOrigin {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		return
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	if p.credentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// handleCORS aplica a política de CORS. Requisições de origens fora da lista
+// são recusadas antes de chegar aos handlers, inclusive as que não passam por
+// preflight (formulários simples), para que sites de terceiros não consigam
+// alterar a configuração usando a sessão do navegador.
+func (s *Server) handleCORS(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Origin")
+
+		origin := r.Header.Get("Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		if preflight {
+			w.Header().Add("Vary", "Access-Control-Request-Method")
+			w.Header().Add("Vary", "Access-Control-Request-Headers")
+
+			method := strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))
+			requested := r.Header.Get("Access-Control-Request-Headers")
+
+			if origin == "" || !s.cors.allowOrigin(origin) || !s.cors.allowMethod(method) || !s.cors.allowHeaders(requested) {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+
+			s.cors.setOriginHeaders(w, origin)
+			w.Header().Set("Access-Control-Allow-Methods", strings.Join(s.cors.methods, ", "))
+			w.Header().Set("Access-Control-Allow-Headers", strings.Join(s.cors.headers, ", "))
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(s.cors.maxAge))
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if origin != "" {
+			if !s.cors.allowOrigin(origin) {
+				w.Header().Set("Content-Type", "application/json")
+				s.sendErrorCode(w, "Origem não permitida", "origin_not_allowed", http.StatusForbidden, nil)
+				return
+			}
+
+			s.cors.setOriginHeaders(w, origin)
+			w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID")
+		}
+
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Allow", strings.Join(s.cors.methods, ", ")+", OPTIONS")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next(w, r)
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"pix_cli/models"
+)
+
+// corsRequest executa uma requisição crua na API, sem o Content-Type JSON de
+// do, para controlar Origin e os cabeçalhos de preflight
+func (ts *testServer) corsRequest(method, target, body string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	for name, value := range header {
+		req.Header.Set(name, value)
+	}
+
+	rec := httptest.NewRecorder()
+	ts.handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// assertVary confere que a resposta declara variar pelos cabeçalhos informados
+func assertVary(t *testing.T, rec *httptest.ResponseRecorder, names ...string) {
+	t.Helper()
+
+	vary := strings.Join(rec.Header().Values("Vary"), ", ")
+	for _, name := range names {
+		if !strings.Contains(vary, name) {
+			t.Errorf("Vary = %q, sem %s", vary, name)
+		}
+	}
+}
+
+func TestCORSPreflightAllowed(t *testing.T) {
+	ts := newTestServer(t)
+
+	rec := ts.corsRequest(http.MethodOptions, "/api/webhook/config", "", map[string]string{
+		"Origin":                         "http://localhost:3000",
+		"Access-Control-Request-Method":  "POST",
+		"Access-Control-Request-Headers": "content-type, authorization",
+	})
+
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("status = %d, esperado 204", rec.Code)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "http://localhost:3000" {
+		t.Errorf("Access-Control-Allow-Origin = %q", got)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Credentials"); got != "true" {
+		t.Errorf("Access-Control-Allow-Credentials = %q", got)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Methods"); !strings.Contains(got, "POST") {
+		t.Errorf("Access-Control-Allow-Methods = %q", got)
+	}
+	if got := rec.Header().Get("Access-Control-Max-Age"); got != "600" {
+		t.Errorf("Access-Control-Max-Age = %q", got)
+	}
+	assertVary(t, rec, "Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers")
+}
+
+func TestCORSPreflightDenied(t *testing.T) {
+	ts := newTestServer(t)
+
+	cases := []struct {
+		name   string
+		header map[string]string
+	}{
+		{"origem fora da lista", map[string]string{
+			"Origin":                        "https://evil.example",
+			"Access-Control-Request-Method": "POST",
+		}},
+		{"sem origem", map[string]string{
+			"Access-Control-Request-Method": "POST",
+		}},
+		{"método não permitido", map[string]string{
+			"Origin":                        "http://localhost:3000",
+			"Access-Control-Request-Method": "PATCH",
+		}},
+		{"cabeçalho não permitido", map[string]string{
+			"Origin":                         "http://localhost:3000",
+			"Access-Control-Request-Method":  "POST",
+			"Access-Control-Request-Headers": "content-type, x-custom",
+		}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := ts.corsRequest(http.MethodOptions, "/api/webhook/config", "", tc.header)
+
+			if rec.Code != http.StatusForbidden {
+				t.Fatalf("status = %d, esperado 403", rec.Code)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+				t.Errorf("Access-Control-Allow-Origin = %q, esperado vazio", got)
+			}
+			assertVary(t, rec, "Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers")
+		})
+	}
+}
+
+func TestCORSSimpleRequest(t *testing.T) {
+	ts := newTestServer(t)
+	token := ts.login(t, "admin", "admin")
+
+	rec := ts.corsRequest(http.MethodGet, "/api/webhook/list?env=sandbox&type=charge", "", map[string]string{
+		"Origin":        "http://127.0.0.1:3000",
+		"Authorization": "Bearer " + token,
+	})
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, esperado 200: %s", rec.Code, rec.Body)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "http://127.0.0.1:3000" {
+		t.Errorf("Access-Control-Allow-Origin = %q", got)
+	}
+	if got := rec.Header().Get("Access-Control-Expose-Headers"); got != "X-Request-ID" {
+		t.Errorf("Access-Control-Expose-Headers = %q", got)
+	}
+	assertVary(t, rec, "Origin")
+}
+
+func TestCORSSimpleRequestFromDisallowedOrigin(t *testing.T) {
+	ts := newTestServer(t)
+	token := ts.login(t, "admin", "admin")
+
+	status, resp := ts.do(t, token, http.MethodPost, "/api/webhook/config", map[string]interface{}{
+		"env": "sandbox", "type": "charge", "url": "https://example.com/webhook",
+	})
+	if status != http.StatusOK {
+		t.Fatalf("config: status = %d (%s)", status, resp.Error)
+	}
+
+	// Mesmo com sessão válida, a requisição de outra origem não chega ao handler
+	rec := ts.corsRequest(http.MethodDelete, "/api/webhook/delete", `{"env":"sandbox","type":"charge"}`, map[string]string{
+		"Origin":        "https://evil.example",
+		"Authorization": "Bearer " + token,
+		"Content-Type":  "text/plain",
+	})
+
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, esperado 403", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "origin_not_allowed") {
+		t.Errorf("corpo = %s, esperado origin_not_allowed", rec.Body)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Access-Control-Allow-Origin = %q, esperado vazio", got)
+	}
+	assertVary(t, rec, "Origin")
+
+	if _, err := ts.fake("sandbox").ListWebhook(models.WebhookTypeCharge); err != nil {
+		t.Errorf("webhook removido por origem recusada: %v", err)
+	}
+}
+
+func TestLoadCORSPolicy(t *testing.T) {
+	t.Run("curinga com credenciais", func(t *testing.T) {
+		t.Setenv("PIX_CORS_ORIGINS", "*")
+		t.Setenv("PIX_CORS_CREDENTIALS", "")
+
+		if _, err := loadCORSPolicy(); err == nil {
+			t.Fatal("esperado erro para * com credenciais")
+		}
+	})
+
+	t.Run("curinga sem credenciais", func(t *testing.T) {
+		t.Setenv("PIX_CORS_ORIGINS", "*")
+		t.Setenv("PIX_CORS_CREDENTIALS", "false")
+
+		policy, err := loadCORSPolicy()
+		if err != nil {
+			t.Fatalf("loadCORSPolicy: %v", err)
+		}
+		if !policy.anyOrigin || policy.credentials {
+			t.Errorf("anyOrigin = %v, credentials = %v", policy.anyOrigin, policy.credentials)
+		}
+
+		rec := httptest.NewRecorder()
+		policy.setOriginHeaders(rec, "https://qualquer.example")
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+			t.Errorf("Access-Control-Allow-Origin = %q, esperado *", got)
+		}
+		if got := rec.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+			t.Errorf("Access-Control-Allow-Credentials = %q, esperado vazio", got)
+		}
+	})
+
+	t.Run("origem inválida", func(t *testing.T) {
+		t.Setenv("PIX_CORS_ORIGINS", "localhost:3000")
+
+		if _, err := loadCORSPolicy(); err == nil {
+			t.Fatal("esperado erro para origem sem esquema")
+		}
+	})
+
+	t.Run("origem normalizada", func(t *testing.T) {
+		t.Setenv("PIX_CORS_ORIGINS", "HTTPS://Painel.Example.com/")
+
+		policy, err := loadCORSPolicy()
+		if err != nil {
+			t.Fatalf("loadCORSPolicy: %v", err)
+		}
+		if !policy.allowOrigin("https://painel.example.com") {
+			t.Error("origem normalizada não liberada")
+		}
+	})
+}
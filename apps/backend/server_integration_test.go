@@ -0,0 +1,116 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"pix_cli/services"
+	"pix_cli/simulator"
+)
+
+// startSimulator sobe o simulador da API EFI com mTLS em uma porta livre
+func startSimulator(t *testing.T) *simulator.Server {
+	t.Helper()
+
+	pki, err := simulator.NewPKI()
+	if err != nil {
+		t.Fatalf("NewPKI: %v", err)
+	}
+
+	sim := simulator.NewServer(pki, simulator.Config{ClientID: "Client_Id_teste", ClientSecret: "Client_Secret_teste"})
+	if err := sim.Start("127.0.0.1:0"); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(func() { sim.Close() })
+	return sim
+}
+
+// simulatorFactory cria EFIService reais apontados para o simulador
+func simulatorFactory(sim *simulator.Server, clientSecret string) services.ClientFactory {
+	return func(env string) (services.EFIClient, error) {
+		credentials := &services.Credentials{
+			ClientID:     "Client_Id_teste",
+			ClientSecret: clientSecret,
+			Sandbox:      env == "sandbox",
+			Env:          env,
+		}
+		return services.NewEFIServiceWithOptions(credentials, services.EFIOptions{
+			BaseURL:           sim.URL(),
+			RootCAs:           sim.PKI.CAPool,
+			ClientCertificate: &sim.PKI.Client,
+			Retry:             &services.RetryPolicy{MaxAttempts: 1},
+		})
+	}
+}
+
+func TestWebhookAPIAgainstSimulator(t *testing.T) {
+	sim := startSimulator(t)
+	ts := newTestServerWithFactory(t, simulatorFactory(sim, "Client_Secret_teste"))
+	token := ts.login(t, "admin", "admin")
+
+	status, resp := ts.do(t, token, http.MethodGet, "/api/webhook/list?env=sandbox&type=charge", nil)
+	if status != http.StatusOK || resp.Data["exists"] != false {
+		t.Fatalf("list antes da configuração: status %d, data %v (%s)", status, resp.Data, resp.Error)
+	}
+
+	url := "https://pix.example.com/webhook/sandbox"
+	status, resp = ts.do(t, token, http.MethodPost, "/api/webhook/config", map[string]interface{}{
+		"env": "sandbox", "type": "charge", "url": url,
+	})
+	if status != http.StatusOK {
+		t.Fatalf("config: status %d (%s)", status, resp.Error)
+	}
+
+	status, resp = ts.do(t, token, http.MethodGet, "/api/webhook/list?env=sandbox&type=charge", nil)
+	if status != http.StatusOK || resp.Data["webhookUrl"] != url {
+		t.Fatalf("list: status %d, data %v (%s)", status, resp.Data, resp.Error)
+	}
+
+	// O webhook de recorrência é independente do de cobrança
+	status, resp = ts.do(t, token, http.MethodGet, "/api/webhook/list?env=sandbox&type=recurrence", nil)
+	if status != http.StatusOK || resp.Data["exists"] != false {
+		t.Fatalf("list recurrence: status %d, data %v", status, resp.Data)
+	}
+
+	status, resp = ts.do(t, token, http.MethodDelete, "/api/webhook/delete", map[string]interface{}{
+		"env": "sandbox", "type": "charge",
+	})
+	if status != http.StatusOK {
+		t.Fatalf("delete: status %d (%s)", status, resp.Error)
+	}
+
+	status, resp = ts.do(t, token, http.MethodDelete, "/api/webhook/delete", map[string]interface{}{
+		"env": "sandbox", "type": "charge",
+	})
+	if status != http.StatusNotFound || resp.Code != "efi_not_found" {
+		t.Fatalf("delete repetido: status %d, code %q; esperado 404 efi_not_found", status, resp.Code)
+	}
+}
+
+func TestWebhookAPIRejectedBySimulator(t *testing.T) {
+	sim := startSimulator(t)
+	ts := newTestServerWithFactory(t, simulatorFactory(sim, "Client_Secret_teste"))
+	token := ts.login(t, "admin", "admin")
+
+	// A EFI exige https na webhookUrl; o erro de validação chega ao cliente
+	status, resp := ts.do(t, token, http.MethodPost, "/api/webhook/config", map[string]interface{}{
+		"env": "sandbox", "type": "charge", "url": "http://pix.example.com/webhook",
+	})
+	if status != http.StatusBadRequest {
+		t.Fatalf("config http: status %d, code %q; esperado 400", status, resp.Code)
+	}
+}
+
+func TestWebhookAPIWithInvalidCredentials(t *testing.T) {
+	sim := startSimulator(t)
+	ts := newTestServerWithFactory(t, simulatorFactory(sim, "segredo-errado"))
+	token := ts.login(t, "admin", "admin")
+
+	status, resp := ts.do(t, token, http.MethodGet, "/api/webhook/list?env=sandbox&type=charge", nil)
+	// O serviço obtém o token ao ser criado, então a recusa do OAuth aparece
+	// como falha ao carregar o serviço do ambiente
+	if status != http.StatusInternalServerError || !strings.Contains(resp.Error, "401") {
+		t.Fatalf("list com credenciais inválidas: status %d (%s); esperado 500 com o 401 do OAuth", status, resp.Error)
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"pix_cli/logging"
+	"pix_cli/models"
+	"pix_cli/services"
+)
+
+// receiverPath é o prefixo das URLs a registrar na EFI, uma por ambiente e
+// tipo de webhook: https://<host>/webhook/<env>/<tipo>, por exemplo
+// /webhook/sandbox/charge e /webhook/production/recurrence. O tipo no caminho
+// liga a notificação às opções do webhook (segredo e skip-mtls). Ao notificar
+// Pix recebidos a EFI acrescenta /pix à URL registrada; a URL sem sufixo
+// também é aceita.
+const receiverPath = "/webhook/"
+
+// receiverWebhookTypes são os tipos de webhook aceitos no caminho do receptor
+var receiverWebhookTypes = []models.WebhookType{models.WebhookTypeCharge, models.WebhookTypeRecurrence}
+
+// maxEventBody limita o tamanho de uma notificação recebida
+const maxEventBody = 1 << 20
+
+// receiverTarget extrai o ambiente e o tipo de webhook do caminho do receptor
+func receiverTarget(path string) (env string, webhookType string, ok bool) {
+	rest := strings.TrimSuffix(strings.TrimPrefix(path, receiverPath), "/")
+	rest = strings.TrimSuffix(rest, "/pix")
+
+	parts := strings.Split(rest, "/")
+	if len(parts) != 2 {
+		return "", "", false
+	}
+
+	for _, known := range services.KnownEnvironments {
+		if parts[0] != known {
+			continue
+		}
+		for _, t := range receiverWebhookTypes {
+			if parts[1] == string(t) {
+				return known, string(t), true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// handleReceiver recebe as notificações enviadas pela EFI. Cada notificação é
+// gravada antes da resposta e confirmada com 200; a EFI reenvia as que não
+// forem confirmadas, então falhas de gravação respondem 500.
+func (s *Server) handleReceiver(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	env, webhookType, ok := receiverTarget(r.URL.Path)
+	if !ok {
+		s.sendError(w, "Endpoint não encontrado", http.StatusNotFound)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		s.sendError(w, "Método não permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !s.authorizeReceiver(w, r, env, webhookType) {
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxEventBody))
+	if err != nil {
+		s.sendError(w, "Notificação muito grande", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	var payload map[string]json.RawMessage
+	if err := json.Unmarshal(body, &payload); err != nil {
+		slog.WarnContext(r.Context(), "notificação com payload inválido", "env", env, "error", err)
+		s.sendError(w, "Payload inválido", http.StatusBadRequest)
+		return
+	}
+
+	kind := services.EventKind(payload)
+
+	// Payloads fora do modelo são gravados, deduplicados e confirmados mesmo
+	// assim: a EFI reenviaria o mesmo conteúdo, e o evento fica marcado para
+	// análise
+	var invalid string
+	if err := services.ValidateEvent(kind, body); err != nil {
+		slog.WarnContext(r.Context(), "notificação fora do modelo", "env", env, "kind", kind, "error", err)
+		invalid = err.Error()
+	}
+
+	event, err := s.events.Record(services.WebhookEvent{
+		Env:         env,
+		Kind:        kind,
+		WebhookType: webhookType,
+		Path:        r.URL.Path,
+		RemoteAddr:  r.RemoteAddr,
+		RequestID:   logging.RequestID(r.Context()),
+		Payload:     body,
+		Invalid:     invalid,
+		Keys:        services.EventKeys(kind, body),
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "erro ao gravar notificação", "env", env, "error", err)
+		s.sendError(w, "Erro ao gravar notificação", http.StatusInternalServerError)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "notificação recebida", "env", env, "kind", event.Kind, "event_id", event.ID, "duplicate", event.Duplicate)
+
+	// Reentregas são confirmadas com 200 para a EFI parar de reenviar, mas
+	// só as chaves novas seguem para processamento
+	for _, key := range event.NewKeys {
+		slog.InfoContext(r.Context(), "evento processado", "env", env, "key", key, "event_id", event.ID)
+	}
+
+	s.sendSuccess(w, map[string]interface{}{
+		"id":        event.ID,
+		"duplicate": event.Duplicate,
+	})
+}
+
+// handleEvents lista as notificações recebidas. Filtros opcionais: env, kind,
+// duplicate, since e until (RFC 3339) e limit (padrão 50, máximo 1000).
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := services.EventFilter{
+		Env:  query.Get("env"),
+		Kind: query.Get("kind"),
+	}
+
+	switch query.Get("duplicate") {
+	case "":
+	case "true", "false":
+		duplicate := query.Get("duplicate") == "true"
+		filter.Duplicate = &duplicate
+	default:
+		s.sendError(w, "Parâmetro 'duplicate' deve ser true ou false", http.StatusBadRequest)
+		return
+	}
+
+	if filter.Env != "" && filter.Env != "sandbox" && filter.Env != "production" {
+		s.sendError(w, "Ambiente inválido. Use 'sandbox' ou 'production'", http.StatusBadRequest)
+		return
+	}
+
+	var err error
+	if filter.Since, filter.Until, err = queryTimeRange(query); err != nil {
+		s.sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if filter.Limit, err = queryLimit(query, 50); err != nil {
+		s.sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	events, err := s.events.List(filter)
+	if err != nil {
+		s.sendError(w, "Erro ao ler notificações", http.StatusInternalServerError)
+		return
+	}
+
+	s.sendSuccess(w, map[string]interface{}{
+		"events": events,
+		"count":  len(events),
+	})
+}
+
+// handleEventStats resume as entregas recebidas, com as reentregas
+// deduplicadas. Filtro opcional: env.
+func (s *Server) handleEventStats(w http.ResponseWriter, r *http.Request) {
+	env := r.URL.Query().Get("env")
+	if env != "" && env != "sandbox" && env != "production" {
+		s.sendError(w, "Ambiente inválido. Use 'sandbox' ou 'production'", http.StatusBadRequest)
+		return
+	}
+
+	stats, err := s.events.Stats(env)
+	if err != nil {
+		s.sendError(w, "Erro ao ler notificações", http.StatusInternalServerError)
+		return
+	}
+
+	s.sendSuccess(w, map[string]interface{}{
+		"env":   env,
+		"stats": stats,
+	})
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"pix_cli/services"
+)
+
+// receiverConfig configura como o receptor de webhooks autentica a EFI. Com
+// tlsAddr, as notificações chegam por um listener HTTPS dedicado em que a EFI
+// apresenta o próprio certificado de cliente (mTLS), verificado contra a CA
+// configurada para cada ambiente.
+type receiverConfig struct {
+	tlsAddr  string
+	certFile string
+	keyFile  string
+	// clientCAs guarda a CA da EFI por ambiente; ambientes sem CA só recebem
+	// notificações de webhooks configurados com skip-mtls e hmac
+	clientCAs map[string]*x509.CertPool
+	// allowlist restringe, por ambiente, os IPs de origem das notificações
+	allowlist map[string][]*net.IPNet
+	// secretGrace é o tempo em que o segredo anterior continua aceito após
+	// uma troca
+	secretGrace time.Duration
+}
+
+// loadReceiverConfig lê a configuração do receptor das variáveis de ambiente:
+//   - PIX_RECEIVER_ADDR: endereço do listener HTTPS (ex: :8443); vazio mantém
+//     o receptor na porta da API, sem verificação do certificado da EFI
+//   - PIX_RECEIVER_CERT_FILE / PIX_RECEIVER_KEY_FILE: certificado do servidor
+//   - EFI_WEBHOOK_CA_FILE_SANDBOX / EFI_WEBHOOK_CA_FILE_PRODUCTION: CA da EFI
+//     (padrão: ./certs/efi_webhook_ca_<env>.pem)
+//   - PIX_RECEIVER_ALLOWED_IPS_SANDBOX / PIX_RECEIVER_ALLOWED_IPS_PRODUCTION:
+//     IPs ou CIDRs de saída da EFI aceitos (padrão: qualquer origem)
+//   - PIX_WEBHOOK_SECRET_GRACE: validade do segredo anterior após uma troca
+func loadReceiverConfig() (*receiverConfig, error) {
+	config := &receiverConfig{
+		tlsAddr:   os.Getenv("PIX_RECEIVER_ADDR"),
+		allowlist: make(map[string][]*net.IPNet),
+	}
+
+	for _, env := range services.KnownEnvironments {
+		allowlist, err := services.LoadReceiverAllowlist(env)
+		if err != nil {
+			return nil, err
+		}
+		config.allowlist[env] = allowlist
+	}
+
+	grace, err := services.LoadWebhookSecretGrace()
+	if err != nil {
+		return nil, err
+	}
+	config.secretGrace = grace
+
+	if config.tlsAddr == "" {
+		return config, nil
+	}
+
+	config.certFile = os.Getenv("PIX_RECEIVER_CERT_FILE")
+	config.keyFile = os.Getenv("PIX_RECEIVER_KEY_FILE")
+	if config.certFile == "" || config.keyFile == "" {
+		return nil, fmt.Errorf("PIX_RECEIVER_ADDR exige PIX_RECEIVER_CERT_FILE e PIX_RECEIVER_KEY_FILE")
+	}
+
+	clientCAs, err := services.LoadWebhookClientCAs()
+	if err != nil {
+		return nil, err
+	}
+	config.clientCAs = clientCAs
+
+	for _, env := range services.KnownEnvironments {
+		if clientCAs[env] == nil {
+			slog.Warn("CA da EFI não encontrada; notificações do ambiente só serão aceitas com skip-mtls e hmac", "env", env, "path", services.WebhookCAPath(env))
+		}
+	}
+
+	return config, nil
+}
+
+// tlsEnabled indica se o receptor usa o listener HTTPS com mTLS
+func (c *receiverConfig) tlsEnabled() bool {
+	return c.tlsAddr != ""
+}
+
+// newReceiverServer cria o servidor HTTPS do receptor. O certificado de
+// cliente é solicitado no handshake mas verificado no handler, pois a CA
+// depende do ambiente indicado no caminho.
+func (s *Server) newReceiverServer() *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc(receiverPath, s.withRequestID(s.handleReceiver))
+
+	return &http.Server{
+		Addr:    s.receiver.tlsAddr,
+		Handler: mux,
+		TLSConfig: &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			ClientAuth: tls.RequestClientCert,
+		},
+	}
+}
+
+// remoteIP extrai o IP de origem da conexão
+func remoteIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
+
+// authorizeReceiver verifica a origem de uma notificação, nesta ordem:
+//   - com allowlist no ambiente, o IP de origem precisa estar nela;
+//   - um certificado de cliente apresentado ao listener HTTPS precisa ser
+//     válido para a CA do ambiente, e dispensa as demais verificações;
+//   - se o webhook do caminho (env, webhookType) usa segredo, o ?hmac= precisa
+//     ser o dele;
+//   - no listener HTTPS, sem certificado nem segredo, a notificação é
+//     recusada. Webhooks com skip-mtls são configurados sempre com hmac
+//     (ver handleConfigWebhook), então a dispensa do certificado vale apenas
+//     para quem conhece o segredo daquele webhook e não se estende aos demais
+//     webhooks do ambiente.
+//
+// Responde 403 e retorna false se a notificação for recusada.
+func (s *Server) authorizeReceiver(w http.ResponseWriter, r *http.Request, env, webhookType string) bool {
+	if allowlist := s.receiver.allowlist[env]; len(allowlist) > 0 && !services.AllowedIP(allowlist, remoteIP(r)) {
+		slog.WarnContext(r.Context(), "notificação recusada: IP fora da allowlist", "env", env, "remote_addr", r.RemoteAddr)
+		s.sendErrorCode(w, "Origem não permitida", "ip_not_allowed", http.StatusForbidden, nil)
+		return false
+	}
+
+	if s.receiver.tlsEnabled() && r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		if err := services.VerifyWebhookClient(s.receiver.clientCAs[env], r.TLS.PeerCertificates, time.Now()); err != nil {
+			slog.WarnContext(r.Context(), "notificação recusada: certificado de cliente inválido", "env", env, "remote_addr", r.RemoteAddr, "error", err)
+			s.sendErrorCode(w, "Certificado de cliente inválido", "mtls_invalid", http.StatusForbidden, nil)
+			return false
+		}
+		return true
+	}
+
+	required, valid := s.webhookSettings.CheckSecret(env, webhookType, services.WebhookSecretFromQuery(r.URL.Query()), time.Now())
+	if required {
+		if !valid {
+			slog.WarnContext(r.Context(), "notificação recusada: segredo ausente ou inválido", "env", env, "type", webhookType, "remote_addr", r.RemoteAddr)
+			s.sendErrorCode(w, "Segredo do webhook ausente ou inválido", "invalid_secret", http.StatusForbidden, nil)
+		}
+		return valid
+	}
+
+	if !s.receiver.tlsEnabled() {
+		return true
+	}
+
+	slog.WarnContext(r.Context(), "notificação recusada: certificado de cliente ausente", "env", env, "remote_addr", r.RemoteAddr)
+	s.sendErrorCode(w, "Certificado de cliente obrigatório", "mtls_required", http.StatusForbidden, nil)
+	return false
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"pix_cli/models"
+	"pix_cli/services"
+)
+
+// newTLSReceiverTestServer cria o Server de teste com o listener HTTPS do
+// receptor habilitado. As notificações são entregues direto ao handler, sem
+// certificado de cliente.
+func newTLSReceiverTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	ts := newTestServer(t)
+	ts.receiver.tlsAddr = "127.0.0.1:0"
+	return ts
+}
+
+// deliver entrega uma notificação de teste ao receptor do webhook
+// (env, webhookType)
+func (ts *testServer) deliver(env, webhookType, query string) *httptest.ResponseRecorder {
+	target := receiverPath + env + "/" + webhookType
+	if query != "" {
+		target += "?" + query
+	}
+
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(`{"evento":"teste_webhook"}`))
+	rec := httptest.NewRecorder()
+	ts.handleReceiver(rec, req)
+	return rec
+}
+
+func TestSkipMTLSRequiresHMACWithTLSReceiver(t *testing.T) {
+	ts := newTLSReceiverTestServer(t)
+	token := ts.login(t, "admin", "admin")
+
+	status, resp := ts.do(t, token, http.MethodPost, "/api/webhook/config", map[string]interface{}{
+		"env": "sandbox", "type": "charge", "url": "https://example.com/webhook", "skipMtls": true,
+	})
+	if status != http.StatusBadRequest || resp.Code != "skip_mtls_requires_hmac" {
+		t.Fatalf("status = %d, code = %q; esperado 400 skip_mtls_requires_hmac", status, resp.Code)
+	}
+	if _, err := ts.fake("sandbox").ListWebhook(models.WebhookTypeCharge); err == nil {
+		t.Error("webhook registrado na EFI apesar da recusa")
+	}
+	if _, found, _ := ts.webhookSettings.Get("sandbox", "charge"); found {
+		t.Error("opções gravadas apesar da recusa")
+	}
+
+	status, resp = ts.do(t, token, http.MethodPost, "/api/webhook/config", map[string]interface{}{
+		"env": "sandbox", "type": "charge", "url": "https://example.com/webhook", "skipMtls": true, "hmac": true,
+	})
+	if status != http.StatusOK {
+		t.Fatalf("skipMtls com hmac: status = %d (%s)", status, resp.Error)
+	}
+}
+
+func TestSkipMTLSAllowedWithoutTLSReceiver(t *testing.T) {
+	ts := newTestServer(t)
+	token := ts.login(t, "admin", "admin")
+
+	status, resp := ts.do(t, token, http.MethodPost, "/api/webhook/config", map[string]interface{}{
+		"env": "sandbox", "type": "charge", "url": "https://example.com/webhook", "skipMtls": true,
+	})
+	if status != http.StatusOK {
+		t.Fatalf("status = %d (%s); esperado 200 sem listener HTTPS", status, resp.Error)
+	}
+}
+
+func TestSkipMTLSDoesNotExemptEnvironment(t *testing.T) {
+	ts := newTLSReceiverTestServer(t)
+
+	// Opções gravadas antes da exigência de hmac: skip-mtls sem segredo
+	err := ts.webhookSettings.Save(services.WebhookSettings{
+		Env:        "sandbox",
+		Type:       "charge",
+		WebhookURL: "https://example.com/webhook",
+		SkipMTLS:   true,
+	})
+	if err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	rec := ts.deliver("sandbox", "charge", "")
+	if rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), "mtls_required") {
+		t.Fatalf("sem certificado nem segredo: status = %d (%s); esperado 403 mtls_required", rec.Code, rec.Body)
+	}
+}
+
+func TestSkipMTLSWithHMACAcceptsOnlySecret(t *testing.T) {
+	ts := newTLSReceiverTestServer(t)
+	token := ts.login(t, "admin", "admin")
+
+	status, resp := ts.do(t, token, http.MethodPost, "/api/webhook/config", map[string]interface{}{
+		"env": "sandbox", "type": "charge", "url": "https://example.com/webhook", "skipMtls": true, "hmac": true,
+	})
+	if status != http.StatusOK {
+		t.Fatalf("config: status = %d (%s)", status, resp.Error)
+	}
+	settings, found, err := ts.webhookSettings.Get("sandbox", "charge")
+	if err != nil || !found {
+		t.Fatalf("Get: %v (found = %v)", err, found)
+	}
+
+	if rec := ts.deliver("sandbox", "charge", ""); rec.Code != http.StatusForbidden {
+		t.Errorf("sem segredo: status = %d, esperado 403", rec.Code)
+	}
+	if rec := ts.deliver("sandbox", "charge", "hmac=segredo-errado"); rec.Code != http.StatusForbidden {
+		t.Errorf("segredo errado: status = %d, esperado 403", rec.Code)
+	}
+	if rec := ts.deliver("sandbox", "charge", services.WebhookSecretParam+"="+url.QueryEscape(settings.Secret)); rec.Code != http.StatusOK {
+		t.Errorf("segredo correto: status = %d (%s), esperado 200", rec.Code, rec.Body)
+	}
+
+	// O skip-mtls de sandbox não libera production
+	if rec := ts.deliver("production", "charge", ""); rec.Code != http.StatusForbidden {
+		t.Errorf("production sem certificado: status = %d, esperado 403", rec.Code)
+	}
+}
+
+// configureMixedWebhooks configura em sandbox o webhook de cobranças com
+// skip-mtls e hmac e o de recorrências sem segredo, e retorna o segredo do
+// primeiro
+func (ts *testServer) configureMixedWebhooks(t *testing.T) string {
+	t.Helper()
+	token := ts.login(t, "admin", "admin")
+
+	status, resp := ts.do(t, token, http.MethodPost, "/api/webhook/config", map[string]interface{}{
+		"env": "sandbox", "type": "charge", "url": "https://example.com/webhook/sandbox/charge", "skipMtls": true, "hmac": true,
+	})
+	if status != http.StatusOK {
+		t.Fatalf("config charge: status = %d (%s)", status, resp.Error)
+	}
+	status, resp = ts.do(t, token, http.MethodPost, "/api/webhook/config", map[string]interface{}{
+		"env": "sandbox", "type": "recurrence", "url": "https://example.com/webhook/sandbox/recurrence",
+	})
+	if status != http.StatusOK {
+		t.Fatalf("config recurrence: status = %d (%s)", status, resp.Error)
+	}
+
+	settings, found, err := ts.webhookSettings.Get("sandbox", "charge")
+	if err != nil || !found {
+		t.Fatalf("Get: %v (found = %v)", err, found)
+	}
+	return services.WebhookSecretParam + "=" + url.QueryEscape(settings.Secret)
+}
+
+func TestMixedHMACWebhooksWithoutTLSReceiver(t *testing.T) {
+	ts := newTestServer(t)
+	secret := ts.configureMixedWebhooks(t)
+
+	cases := []struct {
+		name        string
+		webhookType string
+		query       string
+		status      int
+	}{
+		{"cobrança sem segredo", "charge", "", http.StatusForbidden},
+		{"cobrança com segredo", "charge", secret, http.StatusOK},
+		{"recorrência sem segredo", "recurrence", "", http.StatusOK},
+		{"recorrência com segredo da cobrança", "recurrence", secret, http.StatusOK},
+	}
+	for _, tc := range cases {
+		if rec := ts.deliver("sandbox", tc.webhookType, tc.query); rec.Code != tc.status {
+			t.Errorf("%s: status = %d (%s), esperado %d", tc.name, rec.Code, rec.Body, tc.status)
+		}
+	}
+}
+
+func TestMixedHMACWebhooksWithTLSReceiver(t *testing.T) {
+	ts := newTLSReceiverTestServer(t)
+	secret := ts.configureMixedWebhooks(t)
+
+	cases := []struct {
+		name        string
+		webhookType string
+		query       string
+		status      int
+		code        string
+	}{
+		{"cobrança sem segredo", "charge", "", http.StatusForbidden, "invalid_secret"},
+		{"cobrança com segredo", "charge", secret, http.StatusOK, ""},
+		// O segredo da cobrança não substitui o certificado de outro webhook
+		{"recorrência com segredo da cobrança", "recurrence", secret, http.StatusForbidden, "mtls_required"},
+		{"recorrência sem certificado", "recurrence", "", http.StatusForbidden, "mtls_required"},
+	}
+	for _, tc := range cases {
+		rec := ts.deliver("sandbox", tc.webhookType, tc.query)
+		if rec.Code != tc.status || !strings.Contains(rec.Body.String(), tc.code) {
+			t.Errorf("%s: status = %d (%s), esperado %d %s", tc.name, rec.Code, rec.Body, tc.status, tc.code)
+		}
+	}
+}
+
+func TestReceiverTarget(t *testing.T) {
+	cases := map[string]string{
+		"/webhook/sandbox/charge":         "sandbox charge",
+		"/webhook/sandbox/charge/pix":     "sandbox charge",
+		"/webhook/production/recurrence/": "production recurrence",
+		"/webhook/sandbox":                "",
+		"/webhook/sandbox/pix":            "",
+		"/webhook/sandbox/outro":          "",
+		"/webhook/homolog/charge":         "",
+		"/webhook/sandbox/charge/extra":   "",
+	}
+
+	for path, want := range cases {
+		env, webhookType, ok := receiverTarget(path)
+		got := ""
+		if ok {
+			got = env + " " + webhookType
+		}
+		if got != want {
+			t.Errorf("receiverTarget(%q) = %q, esperado %q", path, got, want)
+		}
+	}
+}
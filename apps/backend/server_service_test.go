@@ -0,0 +1,82 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+
+	"pix_cli/services"
+)
+
+// countingFactory cria um FakeEFIService novo a cada chamada e conta quantos
+// clientes foram criados por ambiente
+type countingFactory struct {
+	mu     sync.Mutex
+	builds map[string]int
+}
+
+func (f *countingFactory) build(env string) (services.EFIClient, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.builds == nil {
+		f.builds = make(map[string]int)
+	}
+	f.builds[env]++
+	return services.NewFakeEFIService(), nil
+}
+
+func (f *countingFactory) count(env string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.builds[env]
+}
+
+func TestReloadServiceRebuildsClient(t *testing.T) {
+	factory := &countingFactory{}
+	ts := newTestServerWithFactory(t, factory.build)
+	token := ts.login(t, "admin", "admin")
+
+	if status, resp := ts.do(t, token, http.MethodGet, "/api/test-connection?env=sandbox", nil); status != http.StatusOK {
+		t.Fatalf("test-connection: status = %d (%s)", status, resp.Error)
+	}
+	if status, resp := ts.do(t, token, http.MethodGet, "/api/test-connection?env=sandbox", nil); status != http.StatusOK {
+		t.Fatalf("test-connection: status = %d (%s)", status, resp.Error)
+	}
+	if got := factory.count("sandbox"); got != 1 {
+		t.Fatalf("clientes criados antes do reload = %d, esperado 1", got)
+	}
+
+	for i := 2; i <= 3; i++ {
+		if status, resp := ts.do(t, token, http.MethodPost, "/api/reload-service?env=sandbox", nil); status != http.StatusOK {
+			t.Fatalf("reload-service: status = %d (%s)", status, resp.Error)
+		}
+		if got := factory.count("sandbox"); got != i {
+			t.Fatalf("clientes criados após %d reloads = %d, esperado %d", i-1, got, i)
+		}
+	}
+
+	if got := factory.count("production"); got != 0 {
+		t.Errorf("reload de sandbox criou %d clientes de production", got)
+	}
+}
+
+func TestTestConnectionRejectsUnknownEnv(t *testing.T) {
+	factory := &countingFactory{}
+	ts := newTestServerWithFactory(t, factory.build)
+	token := ts.login(t, "admin", "admin")
+
+	for _, env := range []string{"homolog", "..%2F..%2Fetc%2Fpasswd"} {
+		status, resp := ts.do(t, token, http.MethodGet, "/api/test-connection?env="+env, nil)
+		if status != http.StatusBadRequest {
+			t.Errorf("env=%s: status = %d (%s), esperado 400", env, status, resp.Error)
+		}
+	}
+
+	if loaded := ts.registry.Loaded(); len(loaded) != 0 {
+		t.Errorf("ambientes carregados = %v, esperado nenhum", loaded)
+	}
+	if len(factory.builds) != 0 {
+		t.Errorf("clientes criados para ambientes inválidos: %v", factory.builds)
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"pix_cli/services"
+)
+
+// chdirTemp troca o diretório de trabalho por um temporário durante o teste,
+// já que config, certs e data são relativos a ele. Testes que usam chdirTemp
+// não podem rodar com t.Parallel.
+func chdirTemp(t *testing.T) {
+	t.Helper()
+
+	previous, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Getwd: %v", err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatalf("Chdir: %v", err)
+	}
+	t.Cleanup(func() { os.Chdir(previous) })
+}
+
+// testServer reúne o Server de teste e os clientes EFI em memória de cada
+// ambiente
+type testServer struct {
+	*Server
+	handler http.Handler
+
+	mu    sync.Mutex
+	fakes map[string]*services.FakeEFIService
+}
+
+// newTestServer cria um Server sobre um diretório temporário (config, certs e
+// data ficam nele), com FakeEFIService em cada ambiente
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	return newTestServerWithFactory(t, nil)
+}
+
+// newTestServerWithFactory cria o Server com os clientes EFI da factory;
+// nil usa FakeEFIService
+func newTestServerWithFactory(t *testing.T, factory services.ClientFactory) *testServer {
+	t.Helper()
+	chdirTemp(t)
+
+	store, err := services.OpenStore("./data/pix.db")
+	if err != nil {
+		t.Fatalf("OpenStore: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	ts := &testServer{fakes: make(map[string]*services.FakeEFIService)}
+	if factory == nil {
+		factory = func(env string) (services.EFIClient, error) {
+			return ts.fake(env), nil
+		}
+	}
+	registry := services.NewServiceRegistryWithFactory(factory)
+
+	cors, err := loadCORSPolicy()
+	if err != nil {
+		t.Fatalf("loadCORSPolicy: %v", err)
+	}
+
+	monitor := services.NewCertificateMonitor(services.KnownEnvironments, []int{30}, time.Hour)
+	ts.Server = NewServer(registry, monitor, store, services.NewSessionManager(time.Hour), cors, &receiverConfig{}, 0, 0)
+	ts.handler = ts.withRequestID(ts.handleCORS(ts.requireAuth(ts.handleAPI)))
+	return ts
+}
+
+// fake retorna o cliente em memória do ambiente, criando-o na primeira vez
+func (ts *testServer) fake(env string) *services.FakeEFIService {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.fakes[env] == nil {
+		ts.fakes[env] = services.NewFakeEFIService()
+	}
+	return ts.fakes[env]
+}
+
+// login cria o usuário com o papel informado e retorna um token de sessão
+func (ts *testServer) login(t *testing.T, username, role string) string {
+	t.Helper()
+
+	if _, err := ts.users.Create(username, "senha-de-teste-123", role); err != nil {
+		t.Fatalf("Create(%s): %v", username, err)
+	}
+	token, _, err := ts.sessions.Create(services.Principal{Username: username, Role: role, Method: "session"})
+	if err != nil {
+		t.Fatalf("sessions.Create: %v", err)
+	}
+	return token
+}
+
+// apiResponse é o envelope das respostas da API
+type apiResponse struct {
+	Success bool                   `json:"success"`
+	Error   string                 `json:"error"`
+	Code    string                 `json:"code"`
+	Data    map[string]interface{} `json:"data"`
+}
+
+// do executa uma requisição na API com o token informado
+func (ts *testServer) do(t *testing.T, token, method, target string, body interface{}) (int, apiResponse) {
+	t.Helper()
+
+	status, resp, err := ts.request(token, method, target, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return status, resp
+}
+
+// request é do sem *testing.T, para uso fora da goroutine do teste
+func (ts *testServer) request(token, method, target string, body interface{}) (int, apiResponse, error) {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return 0, apiResponse{}, err
+		}
+	}
+
+	req := httptest.NewRequest(method, target, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	rec := httptest.NewRecorder()
+	ts.handler.ServeHTTP(rec, req)
+
+	var resp apiResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		return rec.Code, resp, fmt.Errorf("%s %s: resposta não é JSON: %q", method, target, rec.Body.String())
+	}
+	return rec.Code, resp, nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"pix_cli/controllers"
+	"pix_cli/models"
+	"pix_cli/services"
+)
+
+// configureWebhook registra o webhook na EFI com as opções informadas. As
+// opções são gravadas antes da chamada porque a EFI envia uma notificação de
+// teste à URL durante a configuração, e o receptor já precisa aceitar o
+// segredo novo; se a EFI recusar, as opções anteriores são restauradas.
+func (s *Server) configureWebhook(ctx context.Context, controller *controllers.WebhookController, webhookType models.WebhookType, settings services.WebhookSettings) (*models.WebhookResponse, error) {
+	registeredURL, err := settings.RegisteredURL()
+	if err != nil {
+		return nil, err
+	}
+
+	previous, found, err := s.webhookSettings.Get(settings.Env, settings.Type)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.webhookSettings.Save(settings); err != nil {
+		return nil, err
+	}
+
+	response, err := controller.ConfigWebhookWithOptionsContext(ctx, webhookType, registeredURL, models.WebhookOptions{SkipMTLS: settings.SkipMTLS})
+	if err != nil {
+		var restoreErr error
+		if found {
+			restoreErr = s.webhookSettings.Save(*previous)
+		} else {
+			restoreErr = s.webhookSettings.Delete(settings.Env, settings.Type)
+		}
+		if restoreErr != nil {
+			slog.ErrorContext(ctx, "erro ao restaurar opções do webhook", "env", settings.Env, "type", settings.Type, "error", restoreErr)
+		}
+	}
+
+	return response, err
+}
+
+// handleRotateWebhookSecret troca o segredo de um webhook configurado com
+// hmac e o registra novamente na EFI. O segredo anterior continua aceito pelo
+// receptor durante PIX_WEBHOOK_SECRET_GRACE, para não recusar notificações em
+// trânsito.
+func (s *Server) handleRotateWebhookSecret(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Type string `json:"type"`
+		Env  string `json:"env"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.sendError(w, "Erro ao decodificar requisição", http.StatusBadRequest)
+		return
+	}
+
+	env := req.Env
+	if env == "" {
+		env = "sandbox"
+	}
+	if env != "sandbox" && env != "production" {
+		s.sendError(w, "Ambiente inválido. Use 'sandbox' ou 'production'", http.StatusBadRequest)
+		return
+	}
+
+	controller, err := s.controllerFor(env)
+	if err != nil {
+		s.sendError(w, fmt.Sprintf("Erro ao recarregar serviço: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	webhookType, err := controller.ValidateWebhookType(req.Type)
+	if err != nil {
+		s.sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	current, found, err := s.webhookSettings.Get(env, string(webhookType))
+	if err != nil {
+		s.sendError(w, "Erro ao ler opções do webhook", http.StatusInternalServerError)
+		return
+	}
+	if !found || !current.HMAC {
+		s.sendErrorCode(w, fmt.Sprintf("Webhook %s não usa segredo; configure-o com hmac", req.Type), "webhook_without_secret", http.StatusConflict, nil)
+		return
+	}
+
+	secret, err := services.NewWebhookSecret()
+	if err != nil {
+		s.sendError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	settings := *current
+	settings.UpdatedAt = time.Time{}
+	settings.Rotate(secret, s.receiver.secretGrace, time.Now())
+
+	entry := s.auditEntry(r, env, services.AuditRotateWebhookSecret)
+	entry.Before = map[string]interface{}{"type": string(webhookType), "secret_fingerprint": services.SecretFingerprint(current.Secret)}
+
+	response, err := s.configureWebhook(r.Context(), controller, webhookType, settings)
+	entry.EFIStatus = efiStatus(response, err)
+	if err != nil {
+		s.auditOutcome(entry, err)
+		s.sendEFIError(w, err)
+		return
+	}
+
+	entry.After = map[string]interface{}{
+		"type":                string(webhookType),
+		"secret_fingerprint":  services.SecretFingerprint(settings.Secret),
+		"previous_expires_at": settings.PreviousExpiresAt,
+	}
+	s.auditOutcome(entry, nil)
+
+	registeredURL, _ := settings.RegisteredURL()
+	s.recordWebhookChange(r, env, string(webhookType), "rotate_secret", registeredURL)
+
+	s.sendSuccess(w, map[string]interface{}{
+		"message":             fmt.Sprintf("Segredo do webhook %s trocado com sucesso", req.Type),
+		"type":                req.Type,
+		"url":                 services.RedactURL(registeredURL),
+		"previous_expires_at": settings.PreviousExpiresAt,
+	})
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Ações registradas no log de auditoria
+const (
+	AuditConfigWebhook       = "config_webhook"
+	AuditDeleteWebhook       = "delete_webhook"
+	AuditRotateWebhookSecret = "rotate_webhook_secret"
+	AuditSaveCredentials     = "save_credentials"
+	AuditUploadCertificate   = "upload_certificate"
+	AuditReloadService       = "reload_service"
+	AuditRevealCredentials   = "reveal_credentials"
+)
+
+// AuditEntry registra uma operação sensível feita pela API. Before e After
+// descrevem o estado antes e depois da alteração e têm os segredos ocultados
+// ao serem gravados.
+type AuditEntry struct {
+	Timestamp  time.Time              `json:"timestamp"`
+	Actor      string                 `json:"actor"`
+	Env        string                 `json:"env"`
+	Action     string                 `json:"action"`
+	Outcome    string                 `json:"outcome"`
+	RemoteAddr string                 `json:"remote_addr,omitempty"`
+	RequestID  string                 `json:"request_id,omitempty"`
+	Reason     string                 `json:"reason,omitempty"`
+	Before     map[string]interface{} `json:"before,omitempty"`
+	After      map[string]interface{} `json:"after,omitempty"`
+	// EFIStatus é o status HTTP devolvido pela EFI, quando houve chamada à API
+	EFIStatus int `json:"efi_status,omitempty"`
+}
+
+// AuditFilter seleciona entradas do log de auditoria. Campos vazios não filtram.
+type AuditFilter struct {
+	Env    string
+	Action string
+	Since  time.Time
+	Until  time.Time
+	// Limit limita a quantidade de entradas, mantendo as mais recentes
+	Limit int
+}
+
+// matches indica se a entrada atende ao filtro
+func (f AuditFilter) matches(entry AuditEntry) bool {
+	if f.Env != "" && entry.Env != f.Env {
+		return false
+	}
+	if f.Action != "" && entry.Action != f.Action {
+		return false
+	}
+	if !f.Since.IsZero() && entry.Timestamp.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && entry.Timestamp.After(f.Until) {
+		return false
+	}
+	return true
+}
+
+// AuditLog grava as entradas de auditoria no armazenamento local, somente
+// por acréscimo: não há como alterar ou remover entradas pela API
+type AuditLog struct {
+	store *Store
+}
+
+// NewAuditLog cria o log de auditoria sobre o armazenamento local
+func NewAuditLog(store *Store) *AuditLog {
+	return &AuditLog{store: store}
+}
+
+// Append acrescenta uma entrada ao log
+func (a *AuditLog) Append(entry AuditEntry) error {
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now().UTC()
+	}
+	entry.Before = redactState(entry.Before)
+	entry.After = redactState(entry.After)
+
+	if err := a.store.Append(bucketAudit, entry); err != nil {
+		return fmt.Errorf("erro ao gravar log de auditoria: %v", err)
+	}
+	return nil
+}
+
+// Query retorna as entradas que atendem ao filtro, da mais recente para a
+// mais antiga
+func (a *AuditLog) Query(filter AuditFilter) ([]AuditEntry, error) {
+	entries := []AuditEntry{}
+	err := a.store.ForEach(bucketAudit, func(key string, value json.RawMessage) error {
+		var entry AuditEntry
+		if err := json.Unmarshal(value, &entry); err != nil {
+			return fmt.Errorf("entrada de auditoria inválida: %v", err)
+		}
+		if filter.matches(entry) {
+			entries = append(entries, entry)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Timestamp.After(entries[j].Timestamp)
+	})
+	if filter.Limit > 0 && len(entries) > filter.Limit {
+		entries = entries[:filter.Limit]
+	}
+
+	return entries, nil
+}
+
+// redactState oculta segredos do estado registrado em Before/After
+func redactState(state map[string]interface{}) map[string]interface{} {
+	if state == nil {
+		return nil
+	}
+	return RedactValue(state).(map[string]interface{})
+}
@@ -0,0 +1,323 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// RoleAdmin é o papel do usuário criado pelo comando de bootstrap
+const RoleAdmin = "admin"
+
+// minPasswordLength é o tamanho mínimo aceito para senhas de usuários locais
+const minPasswordLength = 8
+
+// ErrInvalidLogin é retornado para usuário inexistente ou senha incorreta,
+// sem distinguir os dois casos
+var ErrInvalidLogin = errors.New("usuário ou senha inválidos")
+
+// User é um usuário local da interface
+type User struct {
+	Username     string    `json:"username"`
+	PasswordHash string    `json:"password_hash"`
+	Role         string    `json:"role"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// Principal identifica quem fez a requisição autenticada
+type Principal struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
+	// Method é "session" para login com usuário e senha ou "api_token"
+	Method string `json:"method"`
+}
+
+// UserStore guarda os usuários locais no armazenamento local, com as senhas
+// em bcrypt
+type UserStore struct {
+	// mu serializa as alterações, que dependem do estado atual (último administrador)
+	mu    sync.Mutex
+	store *Store
+}
+
+// dummyPasswordHash é comparado quando o usuário não existe, para que o tempo
+// de resposta não revele quais usuários estão cadastrados
+var (
+	dummyHashOnce     sync.Once
+	dummyPasswordHash []byte
+)
+
+func dummyHash() []byte {
+	dummyHashOnce.Do(func() {
+		dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("pix-auto-webhook"), bcrypt.DefaultCost)
+	})
+	return dummyPasswordHash
+}
+
+// NewUserStore cria o cadastro de usuários sobre o armazenamento local
+func NewUserStore(store *Store) *UserStore {
+	return &UserStore{store: store}
+}
+
+// Count retorna quantos usuários estão cadastrados
+func (s *UserStore) Count() int {
+	return s.store.Count(bucketUsers)
+}
+
+// Get retorna o usuário, se existir
+func (s *UserStore) Get(username string) (User, bool) {
+	var user User
+	found, err := s.store.Get(bucketUsers, username, &user)
+	if err != nil || !found {
+		return User{}, false
+	}
+	return user, true
+}
+
+// Create cadastra um novo usuário com a senha em bcrypt
+func (s *UserStore) Create(username, password, role string) (User, error) {
+	username = strings.TrimSpace(username)
+	if username == "" {
+		return User{}, fmt.Errorf("usuário é obrigatório")
+	}
+	if len(password) < minPasswordLength {
+		return User{}, fmt.Errorf("a senha deve ter pelo menos %d caracteres", minPasswordLength)
+	}
+	if !ValidRole(role) {
+		return User{}, fmt.Errorf("papel inválido: %s", role)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return User{}, fmt.Errorf("erro ao gerar hash da senha: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.Get(username); exists {
+		return User{}, fmt.Errorf("usuário %s já existe", username)
+	}
+
+	user := User{
+		Username:     username,
+		PasswordHash: string(hash),
+		Role:         role,
+		CreatedAt:    time.Now().UTC(),
+	}
+	if err := s.store.Put(bucketUsers, username, user); err != nil {
+		return User{}, fmt.Errorf("erro ao salvar usuário: %v", err)
+	}
+
+	return user, nil
+}
+
+// List retorna os usuários em ordem alfabética
+func (s *UserStore) List() []User {
+	users := []User{}
+	s.store.ForEach(bucketUsers, func(key string, value json.RawMessage) error {
+		var user User
+		if err := json.Unmarshal(value, &user); err == nil {
+			users = append(users, user)
+		}
+		return nil
+	})
+	return users
+}
+
+// SetRole altera o papel do usuário. Não permite remover o último administrador.
+func (s *UserStore) SetRole(username, role string) (User, error) {
+	if !ValidRole(role) {
+		return User{}, fmt.Errorf("papel inválido: %s", role)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.Get(username)
+	if !ok {
+		return User{}, fmt.Errorf("usuário %s não encontrado", username)
+	}
+
+	if user.Role == RoleAdmin && role != RoleAdmin && s.adminCount() == 1 {
+		return User{}, fmt.Errorf("não é possível remover o papel do último administrador")
+	}
+
+	user.Role = role
+	if err := s.store.Put(bucketUsers, username, user); err != nil {
+		return User{}, fmt.Errorf("erro ao salvar usuário: %v", err)
+	}
+
+	return user, nil
+}
+
+// Delete remove o usuário. Não permite remover o último administrador.
+func (s *UserStore) Delete(username string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.Get(username)
+	if !ok {
+		return fmt.Errorf("usuário %s não encontrado", username)
+	}
+
+	if user.Role == RoleAdmin && s.adminCount() == 1 {
+		return fmt.Errorf("não é possível remover o último administrador")
+	}
+
+	if err := s.store.Delete(bucketUsers, username); err != nil {
+		return fmt.Errorf("erro ao remover usuário: %v", err)
+	}
+	return nil
+}
+
+// adminCount conta os administradores
+func (s *UserStore) adminCount() int {
+	count := 0
+	for _, user := range s.List() {
+		if user.Role == RoleAdmin {
+			count++
+		}
+	}
+	return count
+}
+
+// Authenticate confere usuário e senha
+func (s *UserStore) Authenticate(username, password string) (User, error) {
+	user, ok := s.Get(username)
+	if !ok {
+		bcrypt.CompareHashAndPassword(dummyHash(), []byte(password))
+		return User{}, ErrInvalidLogin
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return User{}, ErrInvalidLogin
+	}
+
+	return user, nil
+}
+
+// Session é uma sessão aberta pelo login de um usuário local
+type Session struct {
+	Principal Principal
+	ExpiresAt time.Time
+}
+
+// SessionManager mantém as sessões em memória. Apenas o SHA-256 do token é
+// guardado, então um dump da memória não permite reutilizar as sessões.
+type SessionManager struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	sessions map[string]*Session
+}
+
+// NewSessionManager cria o gerenciador com a duração informada para as sessões
+func NewSessionManager(ttl time.Duration) *SessionManager {
+	return &SessionManager{ttl: ttl, sessions: make(map[string]*Session)}
+}
+
+// LoadSessionTTL lê PIX_SESSION_TTL (ex: "12h"); o padrão é 12 horas
+func LoadSessionTTL() (time.Duration, error) {
+	value := os.Getenv("PIX_SESSION_TTL")
+	if value == "" {
+		return 12 * time.Hour, nil
+	}
+
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl <= 0 {
+		return 0, fmt.Errorf("PIX_SESSION_TTL inválido: %s", value)
+	}
+	return ttl, nil
+}
+
+// Create abre uma sessão e retorna o token que deve ser enviado pelo cliente
+func (m *SessionManager) Create(principal Principal) (string, *Session, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", nil, fmt.Errorf("erro ao gerar token de sessão: %v", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+
+	session := &Session{Principal: principal, ExpiresAt: time.Now().Add(m.ttl)}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.pruneLocked()
+	m.sessions[hashSessionToken(token)] = session
+
+	return token, session, nil
+}
+
+// Lookup retorna a sessão do token, se existir e não tiver expirado
+func (m *SessionManager) Lookup(token string) (*Session, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := hashSessionToken(token)
+	session, ok := m.sessions[key]
+	if !ok {
+		return nil, false
+	}
+
+	if time.Now().After(session.ExpiresAt) {
+		delete(m.sessions, key)
+		return nil, false
+	}
+
+	return session, true
+}
+
+// Revoke encerra a sessão do token
+func (m *SessionManager) Revoke(token string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, hashSessionToken(token))
+}
+
+// RevokeUser encerra todas as sessões do usuário
+func (m *SessionManager) RevokeUser(username string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, session := range m.sessions {
+		if session.Principal.Username == username {
+			delete(m.sessions, key)
+		}
+	}
+}
+
+// pruneLocked descarta sessões expiradas; deve ser chamado com m.mu travado
+func (m *SessionManager) pruneLocked() {
+	now := time.Now()
+	for key, session := range m.sessions {
+		if now.After(session.ExpiresAt) {
+			delete(m.sessions, key)
+		}
+	}
+}
+
+func hashSessionToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// MatchAPIToken compara o token recebido com o token estático configurado em
+// tempo constante
+func MatchAPIToken(received, configured string) bool {
+	if configured == "" || received == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(received), []byte(configured)) == 1
+}
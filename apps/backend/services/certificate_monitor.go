@@ -0,0 +1,260 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CertificateStatus descreve o certificado instalado para um ambiente
+type CertificateStatus struct {
+	Env             string           `json:"env"`
+	Exists          bool             `json:"exists"`
+	Format          string           `json:"format,omitempty"`
+	Path            string           `json:"path,omitempty"`
+	Certificate     *CertificateInfo `json:"certificate,omitempty"`
+	DaysUntilExpiry *int             `json:"days_until_expiry,omitempty"`
+	Expired         bool             `json:"expired"`
+	Error           string           `json:"error,omitempty"`
+}
+
+// CertificateWarning é emitido quando um certificado cruza um dos limites de
+// dias configurados antes da expiração
+type CertificateWarning struct {
+	Env             string    `json:"env"`
+	Serial          string    `json:"serial"`
+	NotAfter        time.Time `json:"not_after"`
+	DaysUntilExpiry int       `json:"days_until_expiry"`
+	ThresholdDays   int       `json:"threshold_days"`
+	Message         string    `json:"message"`
+}
+
+// Fingerprint retorna o SHA-256 do certificado em hexadecimal separado por ':'
+func Fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":")
+}
+
+// InspectCertificate lê o certificado instalado para o ambiente (par PEM ou
+// .p12) e retorna seus detalhes. A ausência do certificado não é erro.
+func InspectCertificate(env string, now time.Time) CertificateStatus {
+	status := CertificateStatus{Env: env}
+
+	p12Path, pemCertPath, pemKeyPath := CertificatePaths(env)
+
+	var loader CertificateLoader
+	switch {
+	case fileExists(pemCertPath) && fileExists(pemKeyPath):
+		status.Format, status.Path = "pem", pemCertPath
+		loader = &PEMLoader{CertPath: pemCertPath, KeyPath: pemKeyPath}
+	case fileExists(p12Path):
+		password := ""
+		if creds, err := LoadCredentialsWithEnv(env); err == nil {
+			password = creds.CertificatePassword
+		}
+		status.Format, status.Path = "p12", p12Path
+		loader = &P12Loader{Path: p12Path, Password: password}
+	default:
+		return status
+	}
+
+	status.Exists = true
+
+	cert, err := loader.Load()
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+
+	info := DescribeCertificate(cert.Leaf)
+	days := daysUntil(cert.Leaf.NotAfter, now)
+	status.Certificate = &info
+	status.DaysUntilExpiry = &days
+	status.Expired = now.After(cert.Leaf.NotAfter)
+
+	return status
+}
+
+// daysUntil arredonda para baixo os dias restantes até t
+func daysUntil(t, now time.Time) int {
+	return int(t.Sub(now).Hours() / 24)
+}
+
+// CertificateMonitor verifica periodicamente a validade dos certificados dos
+// ambientes e registra avisos ao cruzar os limites configurados (ex: 30, 7 e
+// 1 dia antes da expiração)
+type CertificateMonitor struct {
+	envs       []string
+	thresholds []int
+	interval   time.Duration
+
+	mu       sync.Mutex
+	statuses map[string]CertificateStatus
+	warnings map[string]CertificateWarning
+}
+
+// NewCertificateMonitor cria o monitor. thresholds são dias antes da expiração.
+func NewCertificateMonitor(envs []string, thresholds []int, interval time.Duration) *CertificateMonitor {
+	sorted := append([]int(nil), thresholds...)
+	sort.Sort(sort.Reverse(sort.IntSlice(sorted)))
+
+	return &CertificateMonitor{
+		envs:       envs,
+		thresholds: sorted,
+		interval:   interval,
+		statuses:   make(map[string]CertificateStatus),
+		warnings:   make(map[string]CertificateWarning),
+	}
+}
+
+// NewCertificateMonitorFromEnv lê CERT_EXPIRY_WARN_DAYS (ex: "30,7,1") e
+// CERT_CHECK_INTERVAL (ex: "1h") das variáveis de ambiente
+func NewCertificateMonitorFromEnv(envs []string) (*CertificateMonitor, error) {
+	thresholds := []int{30, 7, 1}
+	if value := os.Getenv("CERT_EXPIRY_WARN_DAYS"); value != "" {
+		thresholds = nil
+		for _, part := range strings.Split(value, ",") {
+			days, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil || days < 0 {
+				return nil, fmt.Errorf("CERT_EXPIRY_WARN_DAYS inválido: %s", value)
+			}
+			thresholds = append(thresholds, days)
+		}
+	}
+
+	interval := time.Hour
+	if value := os.Getenv("CERT_CHECK_INTERVAL"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			return nil, fmt.Errorf("CERT_CHECK_INTERVAL inválido: %s", value)
+		}
+		interval = parsed
+	}
+
+	return NewCertificateMonitor(envs, thresholds, interval), nil
+}
+
+// Run verifica os certificados imediatamente e depois a cada intervalo, até ctx ser cancelado
+func (m *CertificateMonitor) Run(ctx context.Context) {
+	m.Check()
+
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.Check()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Check inspeciona os certificados de todos os ambientes e atualiza os avisos
+func (m *CertificateMonitor) Check() {
+	now := time.Now()
+
+	for _, env := range m.envs {
+		status := InspectCertificate(env, now)
+		warning, hasWarning := m.evaluate(status)
+
+		m.mu.Lock()
+		previous, hadWarning := m.warnings[env]
+		m.statuses[env] = status
+		if hasWarning {
+			m.warnings[env] = warning
+		} else {
+			delete(m.warnings, env)
+		}
+		m.mu.Unlock()
+
+		// Registra no log só quando o aviso muda, para não repetir a cada verificação
+		if hasWarning && (!hadWarning || previous.ThresholdDays != warning.ThresholdDays || previous.Serial != warning.Serial) {
+			slog.Warn(warning.Message, "env", env, "serial", warning.Serial, "days_until_expiry", warning.DaysUntilExpiry, "threshold_days", warning.ThresholdDays)
+		}
+		if status.Error != "" {
+			slog.Error("erro ao ler certificado", "env", env, "path", status.Path, "error", status.Error)
+		}
+	}
+}
+
+// evaluate retorna o aviso correspondente ao menor limite já cruzado
+func (m *CertificateMonitor) evaluate(status CertificateStatus) (CertificateWarning, bool) {
+	if status.Certificate == nil || status.DaysUntilExpiry == nil {
+		return CertificateWarning{}, false
+	}
+
+	days := *status.DaysUntilExpiry
+	warning := CertificateWarning{
+		Env:             status.Env,
+		Serial:          status.Certificate.SerialNumber,
+		NotAfter:        status.Certificate.NotAfter,
+		DaysUntilExpiry: days,
+		ThresholdDays:   -1,
+	}
+
+	if status.Expired {
+		warning.ThresholdDays = 0
+		warning.Message = fmt.Sprintf("Certificado %s (serial %s) expirou em %s", status.Env, warning.Serial, warning.NotAfter.Format(time.RFC3339))
+		return warning, true
+	}
+
+	for _, threshold := range m.thresholds {
+		if days <= threshold {
+			warning.ThresholdDays = threshold
+		}
+	}
+
+	if warning.ThresholdDays < 0 {
+		return CertificateWarning{}, false
+	}
+
+	warning.Message = fmt.Sprintf("Certificado %s (serial %s) expira em %d dia(s), em %s", status.Env, warning.Serial, days, warning.NotAfter.Format(time.RFC3339))
+	return warning, true
+}
+
+// Statuses retorna o resultado da última verificação de cada ambiente
+func (m *CertificateMonitor) Statuses() []CertificateStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	statuses := make([]CertificateStatus, 0, len(m.envs))
+	for _, env := range m.envs {
+		if status, ok := m.statuses[env]; ok {
+			statuses = append(statuses, status)
+		}
+	}
+	return statuses
+}
+
+// Warnings retorna os avisos de expiração ativos
+func (m *CertificateMonitor) Warnings() []CertificateWarning {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	warnings := make([]CertificateWarning, 0, len(m.warnings))
+	for _, env := range m.envs {
+		if warning, ok := m.warnings[env]; ok {
+			warnings = append(warnings, warning)
+		}
+	}
+	return warnings
+}
+
+// Thresholds retorna os limites configurados, em dias
+func (m *CertificateMonitor) Thresholds() []int {
+	return append([]int(nil), m.thresholds...)
+}
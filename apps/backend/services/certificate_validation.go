@@ -0,0 +1,311 @@
+package services
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// CertificateInfo resume os dados de um certificado de cliente
+type CertificateInfo struct {
+	Subject      string    `json:"subject"`
+	Issuer       string    `json:"issuer"`
+	SerialNumber string    `json:"serial"`
+	Fingerprint  string    `json:"sha256_fingerprint"`
+	NotBefore    time.Time `json:"not_before"`
+	NotAfter     time.Time `json:"not_after"`
+}
+
+func DescribeCertificate(cert *x509.Certificate) CertificateInfo {
+	return CertificateInfo{
+		Subject:      cert.Subject.String(),
+		Issuer:       cert.Issuer.String(),
+		SerialNumber: cert.SerialNumber.Text(16),
+		Fingerprint:  Fingerprint(cert),
+		NotBefore:    cert.NotBefore,
+		NotAfter:     cert.NotAfter,
+	}
+}
+
+// ClientCAPath retorna o arquivo com a cadeia da CA que emite os
+// certificados de aplicação da EFI. Configurável por ambiente em
+// EFI_CLIENT_CA_FILE_SANDBOX / EFI_CLIENT_CA_FILE_PRODUCTION (ou
+// EFI_CLIENT_CA_FILE); o padrão é ./certs/efi_client_ca_<env>.pem.
+func ClientCAPath(env string) string {
+	if path := envSetting("EFI_CLIENT_CA_FILE", env); path != "" {
+		return path
+	}
+	return filepath.Join("./certs", fmt.Sprintf("efi_client_ca_%s.pem", env))
+}
+
+// LoadClientCAs carrega a CA de certificados de aplicação da EFI de cada
+// ambiente. Ambientes sem arquivo ficam de fora do mapa e são validados pelo
+// nome do emissor (ver ValidateCertificate).
+func LoadClientCAs() (map[string]*x509.CertPool, error) {
+	return loadCAPools(ClientCAPath)
+}
+
+// defaultIssuerPatterns identifica certificados emitidos pela EFI (antiga Gerencianet)
+var defaultIssuerPatterns = []string{"efi", "efí", "gerencianet"}
+
+// sandboxIssuerMarkers aparecem no emissor de certificados de homologação
+var sandboxIssuerMarkers = []string{"homolog", "sandbox"}
+
+// ValidateCertificate verifica se o certificado está dentro da validade e se
+// foi emitido pela CA da EFI do ambiente. Com a CA do ambiente configurada
+// (ClientCAPath), a cadeia (folha seguida dos intermediários) é verificada
+// contra ela, e um certificado emitido pela CA do outro ambiente é recusado
+// nos dois sentidos. Sem a CA, vale o nome do emissor: os emissores aceitos
+// podem ser definidos em EFI_CERT_ISSUERS_<ENV> ou EFI_CERT_ISSUERS (lista
+// separada por vírgula, comparada sem diferenciar maiúsculas; "*" desativa a
+// checagem), e emissores de homologação são recusados em produção.
+func ValidateCertificate(chain []*x509.Certificate, env string, pools map[string]*x509.CertPool, now time.Time) error {
+	if len(chain) == 0 {
+		return fmt.Errorf("certificado ausente")
+	}
+	cert := chain[0]
+
+	if now.Before(cert.NotBefore) {
+		return fmt.Errorf("certificado ainda não é válido (válido a partir de %s)", cert.NotBefore.Format(time.RFC3339))
+	}
+
+	if now.After(cert.NotAfter) {
+		return fmt.Errorf("certificado expirado em %s", cert.NotAfter.Format(time.RFC3339))
+	}
+
+	pool := pools[env]
+	if pool != nil && verifyChain(chain, pool, now) == nil {
+		return nil
+	}
+
+	for other, otherPool := range pools {
+		if other != env && verifyChain(chain, otherPool, now) == nil {
+			return fmt.Errorf("certificado emitido pela CA da EFI de %s não pode ser usado em %s", other, env)
+		}
+	}
+
+	if pool != nil {
+		return fmt.Errorf("emissor %q não é a CA da EFI esperada para %s", cert.Issuer.String(), env)
+	}
+
+	return validateIssuer(cert, env)
+}
+
+// validateIssuer confere o nome do emissor quando não há CA configurada para
+// o ambiente
+func validateIssuer(cert *x509.Certificate, env string) error {
+	patterns := defaultIssuerPatterns
+	if configured := envSetting("EFI_CERT_ISSUERS", env); configured != "" {
+		if strings.TrimSpace(configured) == "*" {
+			return nil
+		}
+		patterns = strings.Split(configured, ",")
+	}
+
+	issuer := strings.ToLower(cert.Issuer.String())
+	if !containsAny(issuer, patterns) {
+		return fmt.Errorf("emissor %q não corresponde à CA da EFI esperada para %s", cert.Issuer.String(), env)
+	}
+
+	// Um certificado de homologação nunca deve ser instalado em produção
+	if env == "production" && containsAny(issuer, sandboxIssuerMarkers) {
+		return fmt.Errorf("emissor %q é de homologação e não pode ser usado em produção", cert.Issuer.String())
+	}
+
+	return nil
+}
+
+func containsAny(value string, patterns []string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern != "" && strings.Contains(value, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// CertificateChain retorna a folha seguida dos intermediários de um
+// certificado TLS montado por ParseP12 ou ParsePEMPair
+func CertificateChain(cert tls.Certificate) ([]*x509.Certificate, error) {
+	chain := []*x509.Certificate{cert.Leaf}
+	for _, der := range cert.Certificate[1:] {
+		intermediate, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao ler certificado intermediário: %v", err)
+		}
+		chain = append(chain, intermediate)
+	}
+	return chain, nil
+}
+
+// verifyChain valida a cadeia contra as raízes informadas
+func verifyChain(chain []*x509.Certificate, roots *x509.CertPool, now time.Time) error {
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	_, err := chain[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	return err
+}
+
+// CertificateInstall grava os arquivos de um certificado de forma atômica,
+// guardando a versão anterior de cada arquivo com o sufixo .bak para que a
+// troca possa ser desfeita
+type CertificateInstall struct {
+	touched []installedFile
+}
+
+type installedFile struct {
+	path      string
+	hadBackup bool
+}
+
+// InstallCertificateFiles grava files (caminho → conteúdo) via arquivo
+// temporário + rename e move os arquivos em remove para .bak. A versão
+// anterior de cada caminho é mantida em <caminho>.bak.
+func InstallCertificateFiles(files map[string][]byte, remove []string) (*CertificateInstall, error) {
+	install := &CertificateInstall{}
+
+	for path, data := range files {
+		hadBackup, err := backupFile(path, false)
+		if err != nil {
+			install.Rollback()
+			return nil, err
+		}
+		install.touched = append(install.touched, installedFile{path: path, hadBackup: hadBackup})
+
+		// Arquivos com chave privada ou senha são criptografados em disco
+		if certificateFileMode(path) == 0600 {
+			data, err = sealSecret(data)
+			if err != nil {
+				install.Rollback()
+				return nil, err
+			}
+		}
+
+		if err := writeFileAtomic(path, data, certificateFileMode(path)); err != nil {
+			install.Rollback()
+			return nil, err
+		}
+	}
+
+	for _, path := range remove {
+		hadBackup, err := backupFile(path, true)
+		if err != nil {
+			install.Rollback()
+			return nil, err
+		}
+		if hadBackup {
+			install.touched = append(install.touched, installedFile{path: path, hadBackup: true})
+		}
+	}
+
+	return install, nil
+}
+
+// Rollback restaura a versão anterior de todos os arquivos tocados
+func (i *CertificateInstall) Rollback() error {
+	var firstErr error
+	for idx := len(i.touched) - 1; idx >= 0; idx-- {
+		file := i.touched[idx]
+
+		var err error
+		if file.hadBackup {
+			err = os.Rename(file.path+".bak", file.path)
+		} else {
+			err = os.Remove(file.path)
+			if os.IsNotExist(err) {
+				err = nil
+			}
+		}
+
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("erro ao restaurar %s: %v", file.path, err)
+		}
+	}
+
+	i.touched = nil
+	return firstErr
+}
+
+// backupFile copia (ou move, se move=true) o arquivo atual para <path>.bak.
+// Retorna false se não havia arquivo para guardar.
+func backupFile(path string, move bool) (bool, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("erro ao ler %s: %v", path, err)
+	}
+
+	if move {
+		if err := os.Rename(path, path+".bak"); err != nil {
+			return false, fmt.Errorf("erro ao mover %s para backup: %v", path, err)
+		}
+		return true, nil
+	}
+
+	if err := writeFileAtomic(path+".bak", data, certificateFileMode(path)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// writeFileAtomic grava em um arquivo temporário no mesmo diretório e o renomeia
+// para o destino, para que leitores nunca vejam um arquivo pela metade
+func writeFileAtomic(path string, data []byte, mode os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("erro ao criar arquivo temporário: %v", err)
+	}
+	tmpPath := tmp.Name()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("erro ao gravar %s: %v", path, err)
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("erro ao gravar %s: %v", path, err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("erro ao gravar %s: %v", path, err)
+	}
+
+	if err := os.Chmod(tmpPath, mode); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("erro ao ajustar permissões de %s: %v", path, err)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("erro ao substituir %s: %v", path, err)
+	}
+
+	return nil
+}
+
+// certificateFileMode restringe a leitura dos arquivos que contêm chave privada
+func certificateFileMode(path string) os.FileMode {
+	if strings.HasSuffix(path, ".pem") {
+		return 0644
+	}
+	return 0600
+}
@@ -0,0 +1,146 @@
+package services
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"pix_cli/simulator"
+)
+
+// selfSigned gera um certificado de cliente autoassinado com o nome informado
+// como emissor
+func selfSigned(t *testing.T, commonName string) *x509.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestValidateCertificate(t *testing.T) {
+	sandbox, err := simulator.NewPKI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	production, err := simulator.NewPKI()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pools := map[string]*x509.CertPool{"sandbox": sandbox.CAPool, "production": production.CAPool}
+	onlyProduction := map[string]*x509.CertPool{"production": production.CAPool}
+	now := time.Now()
+
+	cases := []struct {
+		name  string
+		chain []*x509.Certificate
+		env   string
+		pools map[string]*x509.CertPool
+		now   time.Time
+		err   string
+	}{
+		{"sandbox em sandbox", []*x509.Certificate{sandbox.Client.Leaf}, "sandbox", pools, now, ""},
+		{"produção em produção", []*x509.Certificate{production.Client.Leaf}, "production", pools, now, ""},
+		{"sandbox em produção", []*x509.Certificate{sandbox.Client.Leaf}, "production", pools, now, "CA da EFI de sandbox não pode ser usado em production"},
+		{"produção em sandbox", []*x509.Certificate{production.Client.Leaf}, "sandbox", pools, now, "CA da EFI de production não pode ser usado em sandbox"},
+		{"autoassinado com nome da EFI", []*x509.Certificate{selfSigned(t, "EFI Pay Homologação")}, "sandbox", pools, now, "não é a CA da EFI esperada"},
+		{"certificado de servidor", []*x509.Certificate{sandbox.Server.Leaf}, "sandbox", pools, now, "não é a CA da EFI esperada"},
+		{"sem CA de sandbox, emissor da EFI", []*x509.Certificate{sandbox.Client.Leaf}, "sandbox", onlyProduction, now, ""},
+		{"sem CA de sandbox, certificado de produção", []*x509.Certificate{production.Client.Leaf}, "sandbox", onlyProduction, now, "CA da EFI de production não pode ser usado em sandbox"},
+		{"sem CA, emissor da EFI", []*x509.Certificate{selfSigned(t, "EFI Pay CA")}, "production", nil, now, ""},
+		{"sem CA, emissor de homologação em produção", []*x509.Certificate{selfSigned(t, "EFI Pay Homologação")}, "production", nil, now, "é de homologação"},
+		{"sem CA, outro emissor", []*x509.Certificate{selfSigned(t, "Outra CA")}, "sandbox", nil, now, "não corresponde à CA da EFI"},
+		{"expirado", []*x509.Certificate{sandbox.Client.Leaf}, "sandbox", pools, now.AddDate(1, 0, 0), "certificado expirado"},
+		{"ainda não válido", []*x509.Certificate{sandbox.Client.Leaf}, "sandbox", pools, now.Add(-24 * time.Hour), "ainda não é válido"},
+		{"sem certificado", nil, "sandbox", pools, now, "certificado ausente"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateCertificate(tc.chain, tc.env, tc.pools, tc.now)
+			if tc.err == "" {
+				if err != nil {
+					t.Fatalf("ValidateCertificate: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("erro = %v, esperado %q", err, tc.err)
+			}
+		})
+	}
+}
+
+func TestLoadClientCAs(t *testing.T) {
+	sandbox, err := simulator.NewPKI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := sandbox.WriteFiles(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("EFI_CLIENT_CA_FILE_SANDBOX", dir+"/ca.pem")
+	t.Setenv("EFI_CLIENT_CA_FILE_PRODUCTION", dir+"/inexistente.pem")
+
+	pools, err := LoadClientCAs()
+	if err != nil {
+		t.Fatalf("LoadClientCAs: %v", err)
+	}
+	if pools["sandbox"] == nil || pools["production"] != nil {
+		t.Fatalf("pools = %v, esperado apenas sandbox", pools)
+	}
+
+	chain, err := CertificateChain(sandbox.Client)
+	if err != nil {
+		t.Fatalf("CertificateChain: %v", err)
+	}
+	if err := ValidateCertificate(chain, "sandbox", pools, time.Now()); err != nil {
+		t.Fatalf("ValidateCertificate: %v", err)
+	}
+
+	t.Setenv("EFI_CLIENT_CA_FILE_PRODUCTION", dir+"/client-key.pem")
+	if _, err := LoadClientCAs(); err == nil {
+		t.Fatal("esperado erro para arquivo sem certificado PEM")
+	}
+}
+
+func TestValidateCertificateConfiguredIssuers(t *testing.T) {
+	cert := selfSigned(t, "Banco Parceiro CA")
+
+	t.Setenv("EFI_CERT_ISSUERS_SANDBOX", "parceiro")
+	if err := ValidateCertificate([]*x509.Certificate{cert}, "sandbox", nil, time.Now()); err != nil {
+		t.Errorf("emissor configurado: %v", err)
+	}
+
+	t.Setenv("EFI_CERT_ISSUERS_SANDBOX", "")
+	t.Setenv("EFI_CERT_ISSUERS", "*")
+	if err := ValidateCertificate([]*x509.Certificate{cert}, "production", nil, time.Now()); err != nil {
+		t.Errorf("checagem desativada: %v", err)
+	}
+}
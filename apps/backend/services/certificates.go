@@ -0,0 +1,240 @@
+package services
+
+import (
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/pkcs12"
+)
+
+// CertificateLoader carrega o certificado de cliente usado no mTLS com a EFI
+type CertificateLoader interface {
+	Load() (tls.Certificate, error)
+}
+
+// P12Loader carrega um arquivo PKCS#12 (.p12), com ou sem senha, contendo o
+// certificado do cliente e, opcionalmente, a cadeia intermediária
+type P12Loader struct {
+	Path     string
+	Password string
+}
+
+// PEMLoader carrega um par de arquivos PEM: certificado (com a cadeia
+// intermediária, se houver) e chave privada
+type PEMLoader struct {
+	CertPath string
+	KeyPath  string
+}
+
+// CertificatePaths retorna os caminhos do .p12 e do par PEM de um ambiente
+func CertificatePaths(env string) (p12Path, pemCertPath, pemKeyPath string) {
+	certsDir := "./certs"
+	p12Path = filepath.Join(certsDir, fmt.Sprintf("certificado_%s.p12", env))
+	pemCertPath = filepath.Join(certsDir, fmt.Sprintf("certificado_%s.pem", env))
+	pemKeyPath = filepath.Join(certsDir, fmt.Sprintf("certificado_%s.key", env))
+	return p12Path, pemCertPath, pemKeyPath
+}
+
+// NewCertificateLoader escolhe o loader adequado às credenciais: par PEM
+// quando CertificateKey estiver preenchido, senão .p12 com a senha salva
+func NewCertificateLoader(credentials *Credentials) CertificateLoader {
+	if credentials.CertificateKey != "" {
+		return &PEMLoader{CertPath: credentials.Certificate, KeyPath: credentials.CertificateKey}
+	}
+	return &P12Loader{Path: credentials.Certificate, Password: credentials.CertificatePassword}
+}
+
+func (l *P12Loader) Load() (tls.Certificate, error) {
+	if _, err := os.Stat(l.Path); os.IsNotExist(err) {
+		slog.Error("certificado não encontrado", "path", l.Path)
+		return tls.Certificate{}, fmt.Errorf("certificado não encontrado: %s", l.Path)
+	}
+
+	data, err := ReadSecretFile(l.Path)
+	if err != nil {
+		slog.Error("erro ao ler certificado", "path", l.Path, "error", err)
+		return tls.Certificate{}, fmt.Errorf("erro ao ler certificado: %v", err)
+	}
+
+	cert, err := ParseP12(data, l.Password)
+	if err != nil {
+		slog.Error("erro ao decodificar certificado P12", "path", l.Path, "error", err)
+		return tls.Certificate{}, err
+	}
+
+	slog.Debug("certificado P12 carregado", "path", l.Path, "chain", len(cert.Certificate), "subject", cert.Leaf.Subject.String(), "issuer", cert.Leaf.Issuer.String())
+	return cert, nil
+}
+
+func (l *PEMLoader) Load() (tls.Certificate, error) {
+	certPEM, err := os.ReadFile(l.CertPath)
+	if err != nil {
+		slog.Error("erro ao ler certificado", "path", l.CertPath, "error", err)
+		return tls.Certificate{}, fmt.Errorf("erro ao ler certificado: %v", err)
+	}
+
+	keyPEM, err := ReadSecretFile(l.KeyPath)
+	if err != nil {
+		slog.Error("erro ao ler chave privada", "path", l.KeyPath, "error", err)
+		return tls.Certificate{}, fmt.Errorf("erro ao ler chave privada: %v", err)
+	}
+
+	cert, err := ParsePEMPair(certPEM, keyPEM)
+	if err != nil {
+		slog.Error("erro ao decodificar par PEM", "path", l.CertPath, "error", err)
+		return tls.Certificate{}, err
+	}
+
+	slog.Debug("certificado PEM carregado", "path", l.CertPath, "chain", len(cert.Certificate), "subject", cert.Leaf.Subject.String(), "issuer", cert.Leaf.Issuer.String())
+	return cert, nil
+}
+
+// ParseP12 decodifica um PKCS#12 e monta o certificado TLS com o certificado
+// do cliente primeiro, seguido dos intermediários presentes no arquivo
+func ParseP12(data []byte, password string) (tls.Certificate, error) {
+	blocks, err := pkcs12.ToPEM(data, password)
+	if err != nil {
+		if errors.Is(err, pkcs12.ErrIncorrectPassword) {
+			return tls.Certificate{}, fmt.Errorf("senha do certificado P12 incorreta")
+		}
+		return tls.Certificate{}, fmt.Errorf("erro ao decodificar certificado P12: %v", err)
+	}
+
+	var certs []*x509.Certificate
+	var key crypto.PrivateKey
+	for _, block := range blocks {
+		switch {
+		case block.Type == "CERTIFICATE":
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return tls.Certificate{}, fmt.Errorf("erro ao ler certificado do P12: %v", err)
+			}
+			certs = append(certs, cert)
+		case strings.HasSuffix(block.Type, "PRIVATE KEY"):
+			if key != nil {
+				return tls.Certificate{}, fmt.Errorf("o P12 contém mais de uma chave privada")
+			}
+			if key, err = parsePrivateKey(block.Bytes); err != nil {
+				return tls.Certificate{}, err
+			}
+		}
+	}
+
+	if key == nil {
+		return tls.Certificate{}, fmt.Errorf("o P12 não contém chave privada")
+	}
+
+	return buildCertificate(certs, key)
+}
+
+// ParsePEMPair decodifica um certificado PEM (com cadeia opcional) e sua chave
+func ParsePEMPair(certPEM, keyPEM []byte) (tls.Certificate, error) {
+	var certs []*x509.Certificate
+	for rest := certPEM; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return tls.Certificate{}, fmt.Errorf("erro ao ler certificado PEM: %v", err)
+		}
+		certs = append(certs, cert)
+	}
+
+	var key crypto.PrivateKey
+	for rest := keyPEM; key == nil; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			return tls.Certificate{}, fmt.Errorf("nenhuma chave privada encontrada no PEM")
+		}
+		if !strings.HasSuffix(block.Type, "PRIVATE KEY") {
+			continue
+		}
+		if strings.Contains(block.Headers["Proc-Type"], "ENCRYPTED") {
+			return tls.Certificate{}, fmt.Errorf("chaves PEM criptografadas não são suportadas")
+		}
+
+		var err error
+		if key, err = parsePrivateKey(block.Bytes); err != nil {
+			return tls.Certificate{}, err
+		}
+	}
+
+	return buildCertificate(certs, key)
+}
+
+// buildCertificate identifica o certificado que corresponde à chave e o
+// coloca à frente dos demais, que formam a cadeia enviada no handshake
+func buildCertificate(certs []*x509.Certificate, key crypto.PrivateKey) (tls.Certificate, error) {
+	if len(certs) == 0 {
+		return tls.Certificate{}, fmt.Errorf("nenhum certificado encontrado")
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return tls.Certificate{}, fmt.Errorf("tipo de chave privada não suportado")
+	}
+
+	public, ok := signer.Public().(interface{ Equal(crypto.PublicKey) bool })
+	if !ok {
+		return tls.Certificate{}, fmt.Errorf("tipo de chave pública não suportado")
+	}
+
+	leafIndex := -1
+	for i, cert := range certs {
+		if public.Equal(cert.PublicKey) {
+			leafIndex = i
+			break
+		}
+	}
+
+	if leafIndex < 0 {
+		return tls.Certificate{}, fmt.Errorf("nenhum certificado corresponde à chave privada")
+	}
+
+	leaf := certs[leafIndex]
+	chain := [][]byte{leaf.Raw}
+	for i, cert := range certs {
+		if i != leafIndex {
+			chain = append(chain, cert.Raw)
+		}
+	}
+
+	return tls.Certificate{
+		Certificate: chain,
+		PrivateKey:  key,
+		Leaf:        leaf,
+	}, nil
+}
+
+// parsePrivateKey aceita chaves PKCS#1, PKCS#8 e EC
+func parsePrivateKey(der []byte) (crypto.PrivateKey, error) {
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+
+	if key, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		return key, nil
+	}
+
+	if key, err := x509.ParseECPrivateKey(der); err == nil {
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("formato de chave privada não suportado")
+}
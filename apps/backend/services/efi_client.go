@@ -0,0 +1,25 @@
+package services
+
+import (
+	"context"
+
+	"pix_cli/models"
+)
+
+// EFIClient descreve as operações de webhook da API EFI usadas pelos
+// controllers e pelo servidor. EFIService é a implementação real e
+// FakeEFIService uma implementação em memória para uso sem certificados.
+type EFIClient interface {
+	ConfigWebhook(webhookType models.WebhookType, webhookURL string) (*models.WebhookResponse, error)
+	ListWebhook(webhookType models.WebhookType) (*models.WebhookResponse, error)
+	DeleteWebhook(webhookType models.WebhookType) (*models.WebhookResponse, error)
+	ExecuteWebhookCommand(cmd *models.WebhookCommand) (*models.WebhookResponse, error)
+
+	// Variantes que respeitam cancelamento e prazo do contexto
+	ConfigWebhookContext(ctx context.Context, webhookType models.WebhookType, webhookURL string) (*models.WebhookResponse, error)
+	ListWebhookContext(ctx context.Context, webhookType models.WebhookType) (*models.WebhookResponse, error)
+	DeleteWebhookContext(ctx context.Context, webhookType models.WebhookType) (*models.WebhookResponse, error)
+	ExecuteWebhookCommandContext(ctx context.Context, cmd *models.WebhookCommand) (*models.WebhookResponse, error)
+}
+
+var _ EFIClient = (*EFIService)(nil)
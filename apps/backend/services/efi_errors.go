@@ -0,0 +1,121 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// EFIError representa uma resposta de erro (4xx/5xx) da API EFI.
+// Nome e Mensagem vêm dos campos `nome` e `mensagem` do corpo; no OAuth eles
+// são preenchidos a partir de `error` e `error_description`.
+type EFIError struct {
+	StatusCode int            `json:"status"`
+	Nome       string         `json:"nome,omitempty"`
+	Mensagem   string         `json:"mensagem,omitempty"`
+	Violations []EFIViolation `json:"erros,omitempty"`
+	Method     string         `json:"method"`
+	Path       string         `json:"path"`
+	Body       string         `json:"-"`
+}
+
+// EFIViolation detalha um campo rejeitado pela validação da EFI
+type EFIViolation struct {
+	Chave    string `json:"chave,omitempty"`
+	Caminho  string `json:"caminho,omitempty"`
+	Mensagem string `json:"mensagem,omitempty"`
+}
+
+func (e *EFIError) Error() string {
+	msg := e.Mensagem
+	if msg == "" {
+		msg = strings.TrimSpace(RedactBody([]byte(e.Body)))
+	}
+	if msg == "" {
+		msg = http.StatusText(e.StatusCode)
+	}
+
+	if e.Nome != "" {
+		msg = fmt.Sprintf("%s: %s", e.Nome, msg)
+	}
+
+	for _, v := range e.Violations {
+		msg += fmt.Sprintf(" [%s %s]", v.Caminho, v.Mensagem)
+	}
+
+	return fmt.Sprintf("EFI %s %s retornou %d: %s", e.Method, e.Path, e.StatusCode, msg)
+}
+
+// newEFIError monta um EFIError a partir do corpo de uma resposta de erro
+func newEFIError(method, path string, status int, body []byte) *EFIError {
+	efiErr := &EFIError{
+		StatusCode: status,
+		Method:     method,
+		Path:       path,
+		Body:       string(body),
+	}
+
+	var payload struct {
+		Nome             string         `json:"nome"`
+		Mensagem         string         `json:"mensagem"`
+		Erros            []EFIViolation `json:"erros"`
+		Error            string         `json:"error"`
+		ErrorDescription string         `json:"error_description"`
+	}
+
+	if err := json.Unmarshal(body, &payload); err == nil {
+		efiErr.Nome = payload.Nome
+		efiErr.Mensagem = payload.Mensagem
+		efiErr.Violations = payload.Erros
+
+		if efiErr.Nome == "" {
+			efiErr.Nome = payload.Error
+		}
+		if efiErr.Mensagem == "" {
+			efiErr.Mensagem = payload.ErrorDescription
+		}
+	}
+
+	return efiErr
+}
+
+// AsEFIError extrai o EFIError de err, se houver
+func AsEFIError(err error) (*EFIError, bool) {
+	var efiErr *EFIError
+	if errors.As(err, &efiErr) {
+		return efiErr, true
+	}
+	return nil, false
+}
+
+// IsNotFound indica que o recurso (ex: webhook) não existe na EFI
+func IsNotFound(err error) bool {
+	efiErr, ok := AsEFIError(err)
+	return ok && efiErr.StatusCode == http.StatusNotFound
+}
+
+// IsUnauthorized indica credenciais, token ou certificado recusados pela EFI
+func IsUnauthorized(err error) bool {
+	efiErr, ok := AsEFIError(err)
+	return ok && (efiErr.StatusCode == http.StatusUnauthorized || efiErr.StatusCode == http.StatusForbidden)
+}
+
+// IsValidation indica que a EFI rejeitou os dados enviados
+func IsValidation(err error) bool {
+	efiErr, ok := AsEFIError(err)
+	return ok && (efiErr.StatusCode == http.StatusBadRequest || efiErr.StatusCode == http.StatusUnprocessableEntity)
+}
+
+// IsRateLimited indica que a EFI limitou a taxa de requisições (429)
+func IsRateLimited(err error) bool {
+	efiErr, ok := AsEFIError(err)
+	return ok && efiErr.StatusCode == http.StatusTooManyRequests
+}
+
+// IsServerError indica uma falha do lado da EFI (5xx)
+func IsServerError(err error) bool {
+	efiErr, ok := AsEFIError(err)
+	return ok && efiErr.StatusCode >= 500
+}
@@ -2,21 +2,23 @@ package services
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
-	"golang.org/x/crypto/pkcs12"
-
 	"pix_cli/models"
 )
 
@@ -26,53 +28,90 @@ type Credentials struct {
 	Sandbox      bool   `json:"sandbox"`
 	Env          string `json:"env"`
 	Certificate  string `json:"certificate,omitempty"`
+	// CertificatePassword é a senha do .p12, vazia para arquivos sem senha
+	CertificatePassword string `json:"certificate_password,omitempty"`
+	// CertificateKey é o caminho da chave privada quando o certificado é um par PEM
+	CertificateKey string `json:"certificate_key,omitempty"`
 }
 
 type EFIService struct {
 	credentials *Credentials
 	client      *http.Client
 	baseURL     string
-	accessToken string
+	retry       RetryPolicy
+	timeouts    Timeouts
+
+	tokenMu      sync.Mutex
+	accessToken  string
+	tokenExpiry  time.Time
+	tokenRefresh *tokenRefresh
 }
 
-func NewEFIService(credentials *Credentials) (*EFIService, error) {
-	log.Printf("🔧 [NewEFIService] Iniciando serviço EFI com credenciais: %+v", credentials)
+// tokenRefresh representa uma renovação de token em andamento, compartilhada
+// entre todos os chamadores que precisarem de um token ao mesmo tempo
+type tokenRefresh struct {
+	done chan struct{}
+	err  error
+}
 
-	if _, err := os.Stat(credentials.Certificate); os.IsNotExist(err) {
-		log.Printf("❌ [NewEFIService] Certificado não encontrado: %s", credentials.Certificate)
-		return nil, fmt.Errorf("certificado não encontrado: %s", credentials.Certificate)
-	}
+const (
+	// tokenRefreshMargin antecipa a renovação para evitar usar um token prestes a expirar
+	tokenRefreshMargin = 60 * time.Second
+	// defaultTokenLifetime é usado quando a EFI não informa expires_in
+	defaultTokenLifetime = 3600 * time.Second
+)
 
-	certData, err := os.ReadFile(credentials.Certificate)
-	if err != nil {
-		log.Printf("❌ [NewEFIService] Erro ao ler certificado %s: %v", credentials.Certificate, err)
-		return nil, fmt.Errorf("erro ao ler certificado: %v", err)
-	}
-	log.Printf("✅ [NewEFIService] Certificado lido com sucesso: %s", credentials.Certificate)
+// EFIOptions permite apontar o EFIService para outro endereço da API, como o
+// simulador local, sem alterar as credenciais do ambiente
+type EFIOptions struct {
+	// BaseURL substitui a URL padrão de sandbox/produção quando preenchida
+	BaseURL string
+	// RootCAs define as CAs confiáveis para o servidor; nil usa as do sistema
+	RootCAs *x509.CertPool
+	// ClientCertificate substitui o certificado das credenciais no mTLS
+	ClientCertificate *tls.Certificate
+	// CertificateLoader define como carregar o certificado das credenciais;
+	// nil usa NewCertificateLoader
+	CertificateLoader CertificateLoader
+	// Retry define a política de novas tentativas; nil usa DefaultRetryPolicy
+	Retry *RetryPolicy
+	// Timeouts define o prazo de cada operação; nil usa DefaultTimeouts
+	Timeouts *Timeouts
+}
 
-	privateKey, cert, err := pkcs12.Decode(certData, "")
-	if err != nil {
-		log.Printf("❌ [NewEFIService] Erro ao decodificar certificado P12: %v", err)
-		return nil, fmt.Errorf("erro ao decodificar certificado P12: %v", err)
-	}
-	log.Printf("✅ [NewEFIService] Certificado P12 decodificado com sucesso")
-	log.Printf("🔐 [NewEFIService] Certificado Subject: %s", cert.Subject)
-	log.Printf("🔐 [NewEFIService] Certificado Issuer: %s", cert.Issuer)
+func NewEFIService(credentials *Credentials) (*EFIService, error) {
+	return NewEFIServiceWithOptions(credentials, EFIOptions{})
+}
+
+func NewEFIServiceWithOptions(credentials *Credentials, opts EFIOptions) (*EFIService, error) {
+	slog.Info("iniciando serviço EFI", "env", credentials.Env, "credentials", *credentials)
+
+	var tlsCert tls.Certificate
+	if opts.ClientCertificate != nil {
+		tlsCert = *opts.ClientCertificate
+		slog.Info("usando certificado de cliente informado nas opções", "env", credentials.Env)
+	} else {
+		loader := opts.CertificateLoader
+		if loader == nil {
+			loader = NewCertificateLoader(credentials)
+		}
 
-	tlsCert := tls.Certificate{
-		Certificate: [][]byte{cert.Raw},
-		PrivateKey:  privateKey,
-		Leaf:        cert,
+		cert, err := loader.Load()
+		if err != nil {
+			return nil, err
+		}
+		tlsCert = cert
 	}
 
 	tlsConfig := &tls.Config{
 		Certificates:       []tls.Certificate{tlsCert},
+		RootCAs:            opts.RootCAs,
 		MinVersion:         tls.VersionTLS12,
-		InsecureSkipVerify: false, 
+		InsecureSkipVerify: false,
 	}
 
+	// Sem Timeout fixo: o prazo de cada chamada vem do contexto (ver Timeouts)
 	client := &http.Client{
-		Timeout: 30 * time.Second,
 		Transport: &http.Transport{
 			TLSClientConfig: tlsConfig,
 		},
@@ -82,37 +121,148 @@ func NewEFIService(credentials *Credentials) (*EFIService, error) {
 	if credentials.Sandbox {
 		baseURL = "https://pix-h.api.efipay.com.br"
 	}
+	if opts.BaseURL != "" {
+		baseURL = strings.TrimSuffix(opts.BaseURL, "/")
+	}
 
-	log.Printf("🌐 [NewEFIService] Usando baseURL: %s (Sandbox: %v)", baseURL, credentials.Sandbox)
+	slog.Info("endereço da API EFI definido", "env", credentials.Env, "base_url", baseURL, "sandbox", credentials.Sandbox)
+
+	retry := DefaultRetryPolicy()
+	if opts.Retry != nil {
+		retry = *opts.Retry
+	}
+
+	timeouts := DefaultTimeouts()
+	if opts.Timeouts != nil {
+		timeouts = *opts.Timeouts
+	}
 
 	efiService := &EFIService{
 		credentials: credentials,
 		client:      client,
 		baseURL:     baseURL,
+		retry:       retry,
+		timeouts:    timeouts,
 	}
 
-	log.Printf("🔐 [NewEFIService] Iniciando processo de OAuth2...")
-	if err := efiService.getAccessToken(); err != nil {
-		log.Printf("❌ [NewEFIService] Erro ao obter access token: %v", err)
-		return nil, fmt.Errorf("erro ao obter access token: %v", err)
+	if err := efiService.refreshToken(context.Background()); err != nil {
+		slog.Error("erro ao obter access token", "env", credentials.Env, "error", err)
+		return nil, fmt.Errorf("erro ao obter access token: %w", err)
 	}
 
-	log.Printf("✅ [NewEFIService] Serviço EFI inicializado com sucesso para ambiente: %s", credentials.Env)
+	slog.Info("serviço EFI inicializado", "env", credentials.Env)
 
 	return efiService, nil
 }
 
-func (s *EFIService) getAccessToken() error {
+// LoadEFIOptions lê das variáveis de ambiente as opções de conexão de um
+// ambiente. Para cada variável é consultada primeiro a versão com sufixo do
+// ambiente (ex: EFI_BASE_URL_SANDBOX) e depois a versão sem sufixo:
+//
+//	EFI_BASE_URL          URL base da API (ex: https://127.0.0.1:8443)
+//	EFI_CA_FILE           PEM com as CAs confiáveis para o servidor
+//	EFI_CLIENT_CERT_FILE  PEM com o certificado de cliente (substitui o .p12)
+//	EFI_CLIENT_KEY_FILE   PEM com a chave privada do certificado de cliente
+//	EFI_RETRY_MAX_ATTEMPTS  total de tentativas por chamada (1 desativa o retry)
+//	EFI_RETRY_BASE_DELAY    espera inicial entre tentativas (ex: 500ms)
+//	EFI_RETRY_MAX_DELAY     espera máxima entre tentativas (ex: 10s)
+//	EFI_TIMEOUT_TOKEN       prazo para obter o access token (ex: 30s)
+//	EFI_TIMEOUT_CONFIG      prazo para configurar um webhook
+//	EFI_TIMEOUT_LIST        prazo para consultar um webhook
+//	EFI_TIMEOUT_DELETE      prazo para remover um webhook
+func LoadEFIOptions(env string) (EFIOptions, error) {
+	opts := EFIOptions{
+		BaseURL: envSetting("EFI_BASE_URL", env),
+	}
+
+	retry, err := loadRetryPolicy(env)
+	if err != nil {
+		return opts, err
+	}
+	opts.Retry = &retry
+
+	timeouts, err := loadTimeouts(env)
+	if err != nil {
+		return opts, err
+	}
+	opts.Timeouts = &timeouts
+
+	if caFile := envSetting("EFI_CA_FILE", env); caFile != "" {
+		caPEM, err := os.ReadFile(caFile)
+		if err != nil {
+			return opts, fmt.Errorf("erro ao ler CA %s: %v", caFile, err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return opts, fmt.Errorf("nenhum certificado válido em %s", caFile)
+		}
+		opts.RootCAs = pool
+	}
+
+	certFile := envSetting("EFI_CLIENT_CERT_FILE", env)
+	keyFile := envSetting("EFI_CLIENT_KEY_FILE", env)
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return opts, fmt.Errorf("erro ao carregar certificado de cliente PEM: %v", err)
+		}
+		opts.ClientCertificate = &cert
+	}
+
+	return opts, nil
+}
+
+// loadRetryPolicy aplica as variáveis EFI_RETRY_* sobre DefaultRetryPolicy
+func loadRetryPolicy(env string) (RetryPolicy, error) {
+	policy := DefaultRetryPolicy()
+
+	if value := envSetting("EFI_RETRY_MAX_ATTEMPTS", env); value != "" {
+		attempts, err := strconv.Atoi(value)
+		if err != nil || attempts < 1 {
+			return policy, fmt.Errorf("EFI_RETRY_MAX_ATTEMPTS inválido: %s", value)
+		}
+		policy.MaxAttempts = attempts
+	}
+
+	if value := envSetting("EFI_RETRY_BASE_DELAY", env); value != "" {
+		delay, err := time.ParseDuration(value)
+		if err != nil || delay < 0 {
+			return policy, fmt.Errorf("EFI_RETRY_BASE_DELAY inválido: %s", value)
+		}
+		policy.BaseDelay = delay
+	}
+
+	if value := envSetting("EFI_RETRY_MAX_DELAY", env); value != "" {
+		delay, err := time.ParseDuration(value)
+		if err != nil || delay < 0 {
+			return policy, fmt.Errorf("EFI_RETRY_MAX_DELAY inválido: %s", value)
+		}
+		policy.MaxDelay = delay
+	}
+
+	return policy, nil
+}
+
+// envSetting retorna NAME_<ENV> se definida, senão NAME
+func envSetting(name, env string) string {
+	if value := os.Getenv(name + "_" + strings.ToUpper(env)); value != "" {
+		return value
+	}
+	return os.Getenv(name)
+}
+
+// getAccessTokenContext executa o fluxo client_credentials no /oauth/token
+func (s *EFIService) getAccessTokenContext(ctx context.Context) error {
 	authURL := s.baseURL + "/oauth/token"
-	log.Printf("🔐 [getAccessToken] Fazendo requisição OAuth2 para: %s", authURL)
-	log.Printf("🔐 [getAccessToken] Usando credenciais - ClientID: %s, Sandbox: %v", s.credentials.ClientID, s.credentials.Sandbox)
+	slog.DebugContext(ctx, "solicitando access token", "url", authURL, "client_id", s.credentials.ClientID, "env", s.credentials.Env)
 
 	data := url.Values{}
 	data.Set("grant_type", "client_credentials")
 
-	req, err := http.NewRequest("POST", authURL, strings.NewReader(data.Encode()))
+	req, err := http.NewRequestWithContext(ctx, "POST", authURL, strings.NewReader(data.Encode()))
 	if err != nil {
-		log.Printf("❌ [getAccessToken] Erro ao criar requisição OAuth: %v", err)
+		slog.ErrorContext(ctx, "erro ao criar requisição OAuth", "error", err)
 		return fmt.Errorf("erro ao criar requisição OAuth: %v", err)
 	}
 
@@ -120,44 +270,131 @@ func (s *EFIService) getAccessToken() error {
 	req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(auth)))
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	log.Printf("🔐 [getAccessToken] Enviando requisição OAuth2...")
 	resp, err := s.client.Do(req)
 	if err != nil {
-		log.Printf("❌ [getAccessToken] Erro ao executar requisição OAuth: %v", err)
-		return fmt.Errorf("erro ao executar requisição OAuth: %v", err)
+		slog.ErrorContext(ctx, "erro ao executar requisição OAuth", "env", s.credentials.Env, "error", err)
+		return fmt.Errorf("erro ao executar requisição OAuth: %w", err)
 	}
 	defer resp.Body.Close()
 
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Printf("❌ [getAccessToken] Erro ao ler resposta OAuth: %v", err)
+		slog.ErrorContext(ctx, "erro ao ler resposta OAuth", "error", err)
 		return fmt.Errorf("erro ao ler resposta OAuth: %v", err)
 	}
 
-	log.Printf("🔐 [EFI OAuth] Status: %d | Response: %s", resp.StatusCode, string(respBody))
-
 	if resp.StatusCode != 200 {
-		log.Printf("❌ [getAccessToken] Erro HTTP %d: %s", resp.StatusCode, string(respBody))
-		return fmt.Errorf("erro ao obter access token: %s", string(respBody))
+		slog.ErrorContext(ctx, "OAuth recusado pela EFI", "env", s.credentials.Env, "status", resp.StatusCode, "response", RedactBody(respBody))
+		return newEFIError("POST", "/oauth/token", resp.StatusCode, respBody)
 	}
 
 	var tokenResp struct {
 		AccessToken string `json:"access_token"`
 		TokenType   string `json:"token_type"`
+		ExpiresIn   int    `json:"expires_in"`
 	}
 
 	if err := json.Unmarshal(respBody, &tokenResp); err != nil {
-		log.Printf("❌ [getAccessToken] Erro ao decodificar resposta OAuth: %v", err)
+		slog.ErrorContext(ctx, "erro ao decodificar resposta OAuth", "error", err)
 		return fmt.Errorf("erro ao decodificar resposta OAuth: %v", err)
 	}
 
+	if tokenResp.AccessToken == "" {
+		slog.ErrorContext(ctx, "resposta OAuth sem access_token")
+		return fmt.Errorf("resposta OAuth sem access_token")
+	}
+
+	lifetime := time.Duration(tokenResp.ExpiresIn) * time.Second
+	if lifetime <= 0 {
+		lifetime = defaultTokenLifetime
+	}
+
+	s.tokenMu.Lock()
 	s.accessToken = tokenResp.AccessToken
-	log.Printf("✅ [EFI OAuth] Access token obtido com sucesso")
+	s.tokenExpiry = time.Now().Add(lifetime)
+	s.tokenMu.Unlock()
+
+	slog.InfoContext(ctx, "access token obtido", "env", s.credentials.Env, "expires_in", lifetime)
 
 	return nil
 }
 
+// validToken retorna o access token atual, renovando-o antes se estiver ausente ou perto de expirar
+func (s *EFIService) validToken(ctx context.Context) (string, error) {
+	s.tokenMu.Lock()
+	token := s.accessToken
+	fresh := token != "" && time.Now().Add(tokenRefreshMargin).Before(s.tokenExpiry)
+	s.tokenMu.Unlock()
+
+	if fresh {
+		return token, nil
+	}
+
+	slog.InfoContext(ctx, "access token ausente ou perto de expirar, renovando", "env", s.credentials.Env)
+	if err := s.refreshToken(ctx); err != nil {
+		return "", err
+	}
+
+	s.tokenMu.Lock()
+	defer s.tokenMu.Unlock()
+	return s.accessToken, nil
+}
+
+// invalidateToken descarta o token informado, caso ele ainda seja o token atual.
+// Se outro chamador já tiver renovado o token, nada é feito.
+func (s *EFIService) invalidateToken(token string) {
+	s.tokenMu.Lock()
+	defer s.tokenMu.Unlock()
+
+	if s.accessToken == token {
+		s.accessToken = ""
+		s.tokenExpiry = time.Time{}
+	}
+}
+
+// refreshToken obtém um novo access token. Chamadores concorrentes compartilham
+// a mesma requisição ao /oauth/token em vez de dispararem uma cada. O
+// cancelamento de ctx libera apenas o chamador; a renovação segue em andamento
+// para os demais.
+func (s *EFIService) refreshToken(ctx context.Context) error {
+	s.tokenMu.Lock()
+	refresh := s.tokenRefresh
+	if refresh == nil {
+		refresh = &tokenRefresh{done: make(chan struct{})}
+		s.tokenRefresh = refresh
+		go s.runTokenRefresh(refresh)
+	}
+	s.tokenMu.Unlock()
+
+	select {
+	case <-refresh.done:
+		return refresh.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// runTokenRefresh executa a renovação com prazo próprio (Timeouts.Token),
+// desvinculado do contexto de quem a iniciou
+func (s *EFIService) runTokenRefresh(refresh *tokenRefresh) {
+	ctx, cancel := withTimeout(context.Background(), s.timeouts.Token)
+	defer cancel()
+
+	refresh.err = s.getAccessTokenContext(ctx)
+
+	s.tokenMu.Lock()
+	s.tokenRefresh = nil
+	s.tokenMu.Unlock()
+	close(refresh.done)
+}
+
 func (s *EFIService) ExecuteWebhookCommand(cmd *models.WebhookCommand) (*models.WebhookResponse, error) {
+	return s.ExecuteWebhookCommandContext(context.Background(), cmd)
+}
+
+// ExecuteWebhookCommandContext executa o comando respeitando o cancelamento de
+// ctx e o prazo configurado para a ação em Timeouts
+func (s *EFIService) ExecuteWebhookCommandContext(ctx context.Context, cmd *models.WebhookCommand) (*models.WebhookResponse, error) {
 	var endpoint string
 	var method string
 
@@ -210,58 +447,146 @@ func (s *EFIService) ExecuteWebhookCommand(cmd *models.WebhookCommand) (*models.
 
 	url := s.baseURL + "/v2/" + endpoint
 
-	log.Printf("🔍 [EFI API] %s %s", method, url)
+	// Por padrão a EFI valida o mTLS do receptor ao configurar o webhook; a
+	// dispensa é uma escolha explícita de cada configuração
+	header := http.Header{}
+	if cmd.Action == "config" && cmd.SkipMTLS {
+		header.Set("x-skip-mtls-checking", "true")
+	}
+
+	slog.InfoContext(ctx, "chamada à API EFI", "env", s.credentials.Env, "method", method, "url", RedactURL(url))
+
+	ctx, cancel := withTimeout(ctx, s.timeouts.forAction(cmd.Action))
+	defer cancel()
+
+	var resp *http.Response
+	var respBody []byte
+	for attempt := 1; ; attempt++ {
+		resp, respBody, err = s.sendAuthorized(ctx, method, url, jsonBody, header)
+		if ctx.Err() != nil {
+			break
+		}
+
+		wait, retry := s.retry.nextDelay(method, attempt, resp, err)
+		if !retry {
+			break
+		}
+
+		if err != nil {
+			slog.WarnContext(ctx, "chamada à API EFI falhou, repetindo", "method", method, "url", RedactURL(url), "attempt", attempt, "error", err, "wait", wait)
+		} else {
+			slog.WarnContext(ctx, "chamada à API EFI retornou erro transitório, repetindo", "method", method, "url", RedactURL(url), "attempt", attempt, "status", resp.StatusCode, "wait", wait)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("chamada %s %s interrompida: %w", method, "/v2/"+endpoint, ctx.Err())
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "resposta da API EFI", "env", s.credentials.Env, "method", method, "url", RedactURL(url), "status", resp.StatusCode, "response", RedactBody(respBody))
+
+	if resp.StatusCode >= 400 {
+		return nil, newEFIError(method, "/v2/"+endpoint, resp.StatusCode, respBody)
+	}
+
+	responseData := map[string]interface{}{}
+	if len(respBody) > 0 {
+		if err := json.Unmarshal(respBody, &responseData); err != nil {
+			slog.WarnContext(ctx, "não foi possível fazer parse da resposta JSON", "error", err)
+			responseData = map[string]interface{}{
+				"raw_response": string(respBody),
+				"status_code":  resp.StatusCode,
+			}
+		}
+	}
+
+	return &models.WebhookResponse{
+		Code:    resp.StatusCode,
+		Message: "Comando executado com sucesso",
+		Data:    responseData,
+	}, nil
+}
+
+// sendAuthorized executa a requisição com o access token atual. Se a EFI
+// rejeitar o token (401), renova-o uma única vez e repete a requisição.
+func (s *EFIService) sendAuthorized(ctx context.Context, method, url string, jsonBody []byte, header http.Header) (*http.Response, []byte, error) {
+	token, err := s.validToken(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("erro ao obter access token: %w", err)
+	}
+
+	resp, respBody, err := s.send(ctx, method, url, jsonBody, token, header)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, respBody, err
+	}
+
+	slog.WarnContext(ctx, "token rejeitado (401), reautenticando", "method", method, "url", RedactURL(url))
+	s.invalidateToken(token)
+
+	token, err = s.validToken(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("erro ao renovar access token: %w", err)
+	}
+
+	return s.send(ctx, method, url, jsonBody, token, header)
+}
 
+// send executa uma requisição autenticada na API EFI, com os cabeçalhos
+// adicionais informados, e retorna a resposta com o corpo já lido
+func (s *EFIService) send(ctx context.Context, method, url string, jsonBody []byte, token string, header http.Header) (*http.Response, []byte, error) {
 	var req *http.Request
+	var err error
 	if len(jsonBody) > 0 {
-		req, err = http.NewRequest(method, url, bytes.NewBuffer(jsonBody))
+		req, err = http.NewRequestWithContext(ctx, method, url, bytes.NewBuffer(jsonBody))
 	} else {
-		req, err = http.NewRequest(method, url, nil)
+		req, err = http.NewRequestWithContext(ctx, method, url, nil)
 	}
 
 	if err != nil {
-		return nil, fmt.Errorf("erro ao criar requisição: %v", err)
+		return nil, nil, fmt.Errorf("erro ao criar requisição: %v", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+s.accessToken)
-	req.Header.Set("x-skip-mtls-checking", "true") 
+	req.Header.Set("Authorization", "Bearer "+token)
+	for name, values := range header {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
 
 	resp, err := s.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("erro ao executar requisição: %v", err)
+		return nil, nil, fmt.Errorf("erro ao executar requisição: %w", err)
 	}
 	defer resp.Body.Close()
 
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("erro ao ler resposta: %v", err)
+		return nil, nil, fmt.Errorf("erro ao ler resposta: %v", err)
 	}
 
-	log.Printf("📡 [EFI API] Status: %d | Response: %s", resp.StatusCode, string(respBody))
-
-	var responseData map[string]interface{}
-	if err := json.Unmarshal(respBody, &responseData); err != nil {
-		log.Printf("⚠️ [EFI API] Aviso: não foi possível fazer parse da resposta JSON: %v", err)
-		responseData = map[string]interface{}{
-			"raw_response": string(respBody),
-			"status_code":  resp.StatusCode,
-		}
-	}
-
-	return &models.WebhookResponse{
-		Code:    resp.StatusCode,
-		Message: "Comando executado com sucesso",
-		Data:    responseData,
-	}, nil
+	return resp, respBody, nil
 }
 
-func (s *EFIService) getBasicAuth() string {
-	auth := s.credentials.ClientID + ":" + s.credentials.ClientSecret
-	return base64.StdEncoding.EncodeToString([]byte(auth))
+func (s *EFIService) ConfigWebhook(webhookType models.WebhookType, webhookURL string) (*models.WebhookResponse, error) {
+	return s.ConfigWebhookContext(context.Background(), webhookType, webhookURL)
 }
 
-func (s *EFIService) ConfigWebhook(webhookType models.WebhookType, webhookURL string) (*models.WebhookResponse, error) {
+func (s *EFIService) ConfigWebhookContext(ctx context.Context, webhookType models.WebhookType, webhookURL string) (*models.WebhookResponse, error) {
 	cmd := &models.WebhookCommand{
 		Type:   webhookType,
 		Action: "config",
@@ -272,10 +597,14 @@ func (s *EFIService) ConfigWebhook(webhookType models.WebhookType, webhookURL st
 		},
 	}
 
-	return s.ExecuteWebhookCommand(cmd)
+	return s.ExecuteWebhookCommandContext(ctx, cmd)
 }
 
 func (s *EFIService) DeleteWebhook(webhookType models.WebhookType) (*models.WebhookResponse, error) {
+	return s.DeleteWebhookContext(context.Background(), webhookType)
+}
+
+func (s *EFIService) DeleteWebhookContext(ctx context.Context, webhookType models.WebhookType) (*models.WebhookResponse, error) {
 	cmd := &models.WebhookCommand{
 		Type:   webhookType,
 		Action: "delete",
@@ -283,10 +612,14 @@ func (s *EFIService) DeleteWebhook(webhookType models.WebhookType) (*models.Webh
 		Body:   map[string]interface{}{},
 	}
 
-	return s.ExecuteWebhookCommand(cmd)
+	return s.ExecuteWebhookCommandContext(ctx, cmd)
 }
 
 func (s *EFIService) ListWebhook(webhookType models.WebhookType) (*models.WebhookResponse, error) {
+	return s.ListWebhookContext(context.Background(), webhookType)
+}
+
+func (s *EFIService) ListWebhookContext(ctx context.Context, webhookType models.WebhookType) (*models.WebhookResponse, error) {
 	cmd := &models.WebhookCommand{
 		Type:   webhookType,
 		Action: "list",
@@ -294,48 +627,23 @@ func (s *EFIService) ListWebhook(webhookType models.WebhookType) (*models.Webhoo
 		Body:   map[string]interface{}{},
 	}
 
-	return s.ExecuteWebhookCommand(cmd)
+	return s.ExecuteWebhookCommandContext(ctx, cmd)
 }
 
 func LoadCredentials() (*Credentials, error) {
-	env := "sandbox"
-
-	configDir := "./config"
-	configPath := filepath.Join(configDir, fmt.Sprintf("credentials_%s.json", env))
-
-	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		return nil, fmt.Errorf("arquivo de credenciais %s não encontrado", env)
-	}
-
-	configBytes, err := os.ReadFile(configPath)
-	if err != nil {
-		return nil, fmt.Errorf("erro ao ler arquivo de credenciais: %v", err)
-	}
-
-	var creds Credentials
-	if err := json.Unmarshal(configBytes, &creds); err != nil {
-		return nil, fmt.Errorf("erro ao decodificar credenciais do arquivo: %v", err)
-	}
-
-	certsDir := "./certs"
-	creds.Certificate = filepath.Join(certsDir, fmt.Sprintf("certificado_%s.p12", env))
-
-	return &creds, nil
+	return LoadCredentialsWithEnv("sandbox")
 }
 
 func LoadCredentialsWithEnv(env string) (*Credentials, error) {
-	fmt.Printf("🔍 [LoadCredentialsWithEnv] Carregando credenciais para ambiente: %s\n", env)
 
 	configDir := "./config"
 	configPath := filepath.Join(configDir, fmt.Sprintf("credentials_%s.json", env))
 
-	fmt.Printf("🔍 [LoadCredentialsWithEnv] Caminho do arquivo: %s\n", configPath)
-
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
 		return nil, fmt.Errorf("arquivo de credenciais %s não encontrado", env)
 	}
 
-	configBytes, err := os.ReadFile(configPath)
+	configBytes, err := ReadSecretFile(configPath)
 	if err != nil {
 		return nil, fmt.Errorf("erro ao ler arquivo de credenciais: %v", err)
 	}
@@ -345,10 +653,21 @@ func LoadCredentialsWithEnv(env string) (*Credentials, error) {
 		return nil, fmt.Errorf("erro ao decodificar credenciais do arquivo: %v", err)
 	}
 
-	certsDir := "./certs"
-	creds.Certificate = filepath.Join(certsDir, fmt.Sprintf("certificado_%s.p12", env))
+	// Um par PEM enviado para o ambiente tem precedência sobre o .p12
+	p12Path, pemCertPath, pemKeyPath := CertificatePaths(env)
+	creds.Certificate = p12Path
+	creds.CertificateKey = ""
+	if fileExists(pemCertPath) && fileExists(pemKeyPath) {
+		creds.Certificate = pemCertPath
+		creds.CertificateKey = pemKeyPath
+	}
 
-	fmt.Printf("🔍 [LoadCredentialsWithEnv] Credenciais carregadas - Sandbox: %v, Env: %s\n", creds.Sandbox, creds.Env)
+	slog.Debug("credenciais carregadas", "env", env, "path", configPath, "sandbox", creds.Sandbox)
 
 	return &creds, nil
 }
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// eventPruneInterval é o intervalo entre as limpezas de eventos antigos
+const eventPruneInterval = time.Hour
+
+// LoadEventRetention lê PIX_EVENT_RETENTION (ex: "720h"), por quanto tempo os
+// eventos recebidos e as chaves de deduplicação ficam guardados; o padrão é
+// 30 dias e "0" desativa a limpeza
+func LoadEventRetention() (time.Duration, error) {
+	value := os.Getenv("PIX_EVENT_RETENTION")
+	if value == "" {
+		return 30 * 24 * time.Hour, nil
+	}
+
+	retention, err := time.ParseDuration(value)
+	if err != nil || retention < 0 {
+		return 0, fmt.Errorf("PIX_EVENT_RETENTION inválido: %s", value)
+	}
+	return retention, nil
+}
+
+// Prune remove os eventos recebidos antes de before e as chaves lógicas
+// vistas pela última vez antes disso, e compacta o armazenamento se o arquivo
+// tiver acumulado linhas obsoletas. Uma reentrega de chave removida volta a
+// ser considerada nova, por isso a retenção deve ser bem maior que a janela
+// de reenvio da EFI.
+func (e *EventStore) Prune(before time.Time) (int, error) {
+	removed := 0
+
+	err := e.store.Update(func(tx *StoreTx) error {
+		for id, value := range tx.store.buckets[bucketEvents] {
+			var event struct {
+				ReceivedAt time.Time `json:"received_at"`
+			}
+			if err := json.Unmarshal(value, &event); err != nil {
+				return fmt.Errorf("evento %s inválido: %v", id, err)
+			}
+			if event.ReceivedAt.Before(before) {
+				tx.Delete(bucketEvents, id)
+				removed++
+			}
+		}
+
+		for key, value := range tx.store.buckets[bucketEventKeys] {
+			var record eventKeyRecord
+			if err := json.Unmarshal(value, &record); err != nil {
+				return fmt.Errorf("chave de evento %s inválida: %v", key, err)
+			}
+			if record.LastSeen.Before(before) {
+				tx.Delete(bucketEventKeys, key)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("erro ao remover eventos antigos: %v", err)
+	}
+
+	if err := e.store.compactIfStale(); err != nil {
+		return removed, fmt.Errorf("erro ao compactar armazenamento: %v", err)
+	}
+	return removed, nil
+}
+
+// RunRetention remove os eventos mais antigos que retention imediatamente e
+// depois a cada eventPruneInterval, até ctx ser cancelado. Retenção zero
+// mantém todos os eventos.
+func (e *EventStore) RunRetention(ctx context.Context, retention time.Duration) {
+	if retention <= 0 {
+		return
+	}
+
+	prune := func() {
+		removed, err := e.Prune(time.Now().Add(-retention))
+		if err != nil {
+			slog.Error("erro na limpeza de eventos recebidos", "error", err)
+			return
+		}
+		if removed > 0 {
+			slog.Info("eventos antigos removidos", "removed", removed, "retention", retention.String())
+		}
+	}
+
+	prune()
+
+	ticker := time.NewTicker(eventPruneInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			prune()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPruneRemovesOldEventsAndKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pix.db")
+	store, err := OpenStore(path)
+	if err != nil {
+		t.Fatalf("OpenStore: %v", err)
+	}
+	defer store.Close()
+	events := NewEventStore(store)
+
+	now := time.Now().UTC()
+	deliver := func(txid string, receivedAt time.Time) WebhookEvent {
+		t.Helper()
+		payload := []byte(fmt.Sprintf(`{"cobr":[{"txid":%q,"status":"ATIVA"}]}`, txid))
+		event, err := events.Record(WebhookEvent{
+			ReceivedAt: receivedAt,
+			Env:        "sandbox",
+			Kind:       EventKindCobr,
+			Payload:    payload,
+			Keys:       EventKeys(EventKindCobr, payload),
+		})
+		if err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+		return event
+	}
+
+	old := now.Add(-48 * time.Hour)
+	for i := 0; i < 200; i++ {
+		deliver(fmt.Sprintf("antigo%d", i), old)
+	}
+	deliver("recente", now.Add(-time.Hour))
+	// Chave antiga reentregue há pouco continua conhecida
+	deliver("antigo0", now.Add(-time.Hour))
+
+	before, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := events.Prune(now.Add(-24 * time.Hour))
+	if err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+	if removed != 200 {
+		t.Errorf("removidos = %d, esperado 200", removed)
+	}
+
+	stats, err := events.Stats("")
+	if err != nil {
+		t.Fatalf("Stats: %v", err)
+	}
+	if want := (EventStats{Deliveries: 2, LogicalEvents: 2, Duplicates: 1}); stats != want {
+		t.Errorf("Stats = %+v, esperado %+v", stats, want)
+	}
+
+	if after, err := os.Stat(path); err != nil || after.Size() >= before.Size() {
+		t.Errorf("arquivo não foi compactado: %d -> %v (%v)", before.Size(), after.Size(), err)
+	}
+
+	if event := deliver("antigo0", now); !event.Duplicate {
+		t.Error("chave mantida pela reentrega recente deveria continuar duplicada")
+	}
+	if event := deliver("antigo1", now); event.Duplicate {
+		t.Error("chave removida pela retenção deveria voltar a ser nova")
+	}
+}
+
+func TestLoadEventRetention(t *testing.T) {
+	cases := map[string]struct {
+		want    time.Duration
+		invalid bool
+	}{
+		"":      {want: 30 * 24 * time.Hour},
+		"168h":  {want: 168 * time.Hour},
+		"0":     {want: 0},
+		"-1h":   {invalid: true},
+		"30d":   {invalid: true},
+		"muito": {invalid: true},
+	}
+
+	for value, tc := range cases {
+		t.Setenv("PIX_EVENT_RETENTION", value)
+
+		got, err := LoadEventRetention()
+		if (err != nil) != tc.invalid || got != tc.want {
+			t.Errorf("LoadEventRetention(%q) = %v, %v", value, got, err)
+		}
+	}
+}
@@ -0,0 +1,350 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"pix_cli/models"
+)
+
+// Tipos de notificação recebidos da EFI, identificados pelo campo de topo do
+// payload
+const (
+	EventKindPix     = "pix"
+	EventKindRec     = "rec"
+	EventKindCobr    = "cobr"
+	EventKindTest    = "teste"
+	EventKindUnknown = "desconhecido"
+)
+
+// WebhookEvent é uma notificação recebida da EFI pelo receptor de webhooks
+type WebhookEvent struct {
+	ID         string    `json:"id"`
+	ReceivedAt time.Time `json:"received_at"`
+	Env        string    `json:"env"`
+	Kind       string    `json:"kind"`
+	// WebhookType é o webhook (charge ou recurrence) do caminho do receptor
+	WebhookType string          `json:"webhook_type,omitempty"`
+	Path        string          `json:"path"`
+	RemoteAddr  string          `json:"remote_addr,omitempty"`
+	RequestID   string          `json:"request_id,omitempty"`
+	Payload     json.RawMessage `json:"payload"`
+	// Invalid descreve por que o payload não passou na validação dos modelos
+	// de notificação; o evento é gravado mesmo assim para análise
+	Invalid string `json:"invalid,omitempty"`
+	// Keys são as chaves lógicas dos itens da notificação (ver EventKeys)
+	Keys []string `json:"keys,omitempty"`
+	// NewKeys são as chaves vistas pela primeira vez nesta entrega, ou seja,
+	// os itens que devem ser processados
+	NewKeys []string `json:"new_keys,omitempty"`
+	// Duplicate indica uma reentrega: todas as chaves já tinham sido vistas
+	Duplicate bool `json:"duplicate"`
+}
+
+// eventKeyRecord registra a primeira entrega de uma chave lógica e quantas
+// vezes ela chegou
+type eventKeyRecord struct {
+	EventID    string    `json:"event_id"`
+	FirstSeen  time.Time `json:"first_seen"`
+	LastSeen   time.Time `json:"last_seen"`
+	Deliveries int       `json:"deliveries"`
+}
+
+// EventStats resume as entregas recebidas. Deliveries conta cada tentativa de
+// entrega; LogicalEvents, as chaves lógicas distintas; Duplicates, as
+// entregas sem nenhuma chave nova.
+type EventStats struct {
+	Deliveries    int `json:"deliveries"`
+	LogicalEvents int `json:"logical_events"`
+	Duplicates    int `json:"duplicates"`
+	Invalid       int `json:"invalid"`
+}
+
+// EventFilter seleciona eventos recebidos. Campos vazios não filtram.
+type EventFilter struct {
+	Env   string
+	Kind  string
+	Since time.Time
+	Until time.Time
+	// Duplicate filtra reentregas (true) ou primeiras entregas (false)
+	Duplicate *bool
+	// Limit limita a quantidade de eventos, mantendo os mais recentes
+	Limit int
+}
+
+// EventKind identifica o tipo da notificação pelo campo de topo do payload:
+// "pix" (Pix recebidos), "rec" (recorrências do Pix Automático), "cobr"
+// (cobranças automáticas) ou "evento" (teste enviado pela EFI ao configurar
+// o webhook)
+func EventKind(payload map[string]json.RawMessage) string {
+	for _, kind := range []string{EventKindPix, EventKindRec, EventKindCobr} {
+		if _, ok := payload[kind]; ok {
+			return kind
+		}
+	}
+	if _, ok := payload["evento"]; ok {
+		return EventKindTest
+	}
+	return EventKindUnknown
+}
+
+// eventItems é a visão mínima dos payloads usada para extrair as chaves
+// lógicas. Ela ignora campos desconhecidos e formatos fora do modelo, para que
+// a deduplicação não dependa da validação estrita.
+type eventItems struct {
+	Pix []struct {
+		EndToEndID string `json:"endToEndId"`
+		Devolucoes []struct {
+			RtrID  string `json:"rtrId"`
+			Status string `json:"status"`
+		} `json:"devolucoes"`
+	} `json:"pix"`
+	Rec []struct {
+		IDRec  string `json:"idRec"`
+		Status string `json:"status"`
+	} `json:"rec"`
+	Cobr []struct {
+		TxID   string `json:"txid"`
+		Status string `json:"status"`
+	} `json:"cobr"`
+}
+
+// EventKeys retorna as chaves lógicas dos itens da notificação, estáveis
+// entre reentregas da EFI:
+//   - pix: "pix:<endToEndId>", mais "pix:<endToEndId>:devolucao:<rtrId>:<status>"
+//     por devolução, já que as devoluções chegam no mesmo Pix
+//   - rec: "rec:<idRec>:<status>"
+//   - cobr: "cobr:<txid>:<status>"
+//
+// A extração é tolerante: payloads que não passam em ValidateEvent continuam
+// deduplicados pelos identificadores que trouxerem. Itens sem identificador e
+// tipos sem modelo não têm chaves.
+func EventKeys(kind string, payload []byte) []string {
+	// Erros de tipo em campos que não são chaves não impedem a extração:
+	// json.Unmarshal preenche o que conseguir
+	var items eventItems
+	json.Unmarshal(payload, &items)
+
+	var keys []string
+	switch kind {
+	case EventKindPix:
+		for _, pix := range items.Pix {
+			if pix.EndToEndID == "" {
+				continue
+			}
+			keys = append(keys, "pix:"+pix.EndToEndID)
+			for _, ret := range pix.Devolucoes {
+				if ret.RtrID != "" {
+					keys = append(keys, fmt.Sprintf("pix:%s:devolucao:%s:%s", pix.EndToEndID, ret.RtrID, ret.Status))
+				}
+			}
+		}
+	case EventKindRec:
+		for _, rec := range items.Rec {
+			if rec.IDRec != "" {
+				keys = append(keys, fmt.Sprintf("rec:%s:%s", rec.IDRec, rec.Status))
+			}
+		}
+	case EventKindCobr:
+		for _, cobr := range items.Cobr {
+			if cobr.TxID != "" {
+				keys = append(keys, fmt.Sprintf("cobr:%s:%s", cobr.TxID, cobr.Status))
+			}
+		}
+	}
+
+	return keys
+}
+
+// ValidateEvent decodifica o payload com o modelo do tipo da notificação, de
+// forma estrita. Tipos sem modelo não são validados.
+func ValidateEvent(kind string, payload []byte) error {
+	var err error
+	switch kind {
+	case EventKindPix:
+		_, err = models.DecodePixNotification(payload)
+	case EventKindRec:
+		_, err = models.DecodeRecNotification(payload)
+	case EventKindCobr:
+		_, err = models.DecodeCobrNotification(payload)
+	}
+	return err
+}
+
+// EventStore guarda os eventos recebidos no armazenamento local
+type EventStore struct {
+	store *Store
+}
+
+// NewEventStore cria o repositório de eventos sobre o armazenamento local
+func NewEventStore(store *Store) *EventStore {
+	return &EventStore{store: store}
+}
+
+// Record grava a entrega, preenchendo ID e data de recebimento, e marca
+// quais chaves lógicas de event.Keys são novas. A entrega e o registro das
+// chaves são gravados na mesma transação, então entregas concorrentes da
+// mesma notificação nunca são ambas consideradas novas.
+func (e *EventStore) Record(event WebhookEvent) (WebhookEvent, error) {
+	if event.ReceivedAt.IsZero() {
+		event.ReceivedAt = time.Now().UTC()
+	}
+
+	err := e.store.Update(func(tx *StoreTx) error {
+		event.ID = tx.NextKey()
+		if err := indexEvent(tx, &event); err != nil {
+			return err
+		}
+		return tx.Put(bucketEvents, event.ID, event)
+	})
+	if err != nil {
+		return WebhookEvent{}, fmt.Errorf("erro ao gravar evento: %v", err)
+	}
+	return event, nil
+}
+
+// List retorna os eventos que atendem ao filtro, do mais recente para o mais
+// antigo
+func (e *EventStore) List(filter EventFilter) ([]WebhookEvent, error) {
+	events := []WebhookEvent{}
+	err := e.store.ForEach(bucketEvents, func(key string, value json.RawMessage) error {
+		var event WebhookEvent
+		if err := json.Unmarshal(value, &event); err != nil {
+			return fmt.Errorf("evento inválido: %v", err)
+		}
+
+		if filter.Env != "" && event.Env != filter.Env {
+			return nil
+		}
+		if filter.Kind != "" && event.Kind != filter.Kind {
+			return nil
+		}
+		if !filter.Since.IsZero() && event.ReceivedAt.Before(filter.Since) {
+			return nil
+		}
+		if !filter.Until.IsZero() && event.ReceivedAt.After(filter.Until) {
+			return nil
+		}
+		if filter.Duplicate != nil && event.Duplicate != *filter.Duplicate {
+			return nil
+		}
+
+		events = append(events, event)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// As chaves seguem a ordem de recebimento; o mais recente vem primeiro
+	for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
+		events[i], events[j] = events[j], events[i]
+	}
+	if filter.Limit > 0 && len(events) > filter.Limit {
+		events = events[:filter.Limit]
+	}
+
+	return events, nil
+}
+
+// eventKey é a chave do registro de deduplicação; as chaves lógicas são
+// independentes por ambiente
+func eventKey(env, key string) string {
+	return env + "/" + key
+}
+
+// indexEvent registra as chaves lógicas da entrega, preenchendo NewKeys e
+// Duplicate
+func indexEvent(tx *StoreTx, event *WebhookEvent) error {
+	event.NewKeys = nil
+	for _, key := range event.Keys {
+		var record eventKeyRecord
+		found, err := tx.Get(bucketEventKeys, eventKey(event.Env, key), &record)
+		if err != nil {
+			return err
+		}
+
+		if !found {
+			record = eventKeyRecord{EventID: event.ID, FirstSeen: event.ReceivedAt}
+			event.NewKeys = append(event.NewKeys, key)
+		}
+		record.LastSeen = event.ReceivedAt
+		record.Deliveries++
+
+		if err := tx.Put(bucketEventKeys, eventKey(event.Env, key), record); err != nil {
+			return err
+		}
+	}
+
+	event.Duplicate = len(event.Keys) > 0 && len(event.NewKeys) == 0
+	return nil
+}
+
+// Stats resume as entregas do ambiente (vazio considera todos)
+func (e *EventStore) Stats(env string) (EventStats, error) {
+	var stats EventStats
+
+	err := e.store.ForEach(bucketEvents, func(key string, value json.RawMessage) error {
+		var event WebhookEvent
+		if err := json.Unmarshal(value, &event); err != nil {
+			return fmt.Errorf("evento inválido: %v", err)
+		}
+		if env != "" && event.Env != env {
+			return nil
+		}
+
+		stats.Deliveries++
+		if event.Duplicate {
+			stats.Duplicates++
+		}
+		if event.Invalid != "" {
+			stats.Invalid++
+		}
+		return nil
+	})
+	if err != nil {
+		return EventStats{}, err
+	}
+
+	err = e.store.ForEach(bucketEventKeys, func(key string, value json.RawMessage) error {
+		if env == "" || strings.HasPrefix(key, env+"/") {
+			stats.LogicalEvents++
+		}
+		return nil
+	})
+	if err != nil {
+		return EventStats{}, err
+	}
+
+	return stats, nil
+}
+
+// migrateEventKeys indexa as entregas gravadas antes da deduplicação, em
+// ordem de recebimento
+func migrateEventKeys(tx *StoreTx, dir string) error {
+	ids := make([]string, 0, len(tx.store.buckets[bucketEvents]))
+	for id := range tx.store.buckets[bucketEvents] {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		var event WebhookEvent
+		if _, err := tx.Get(bucketEvents, id, &event); err != nil {
+			return err
+		}
+
+		event.Keys = EventKeys(event.Kind, event.Payload)
+		if err := indexEvent(tx, &event); err != nil {
+			return err
+		}
+		if err := tx.Put(bucketEvents, id, event); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
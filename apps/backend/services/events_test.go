@@ -0,0 +1,124 @@
+package services
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestEventKeysIgnoresModelErrors(t *testing.T) {
+	cases := []struct {
+		name    string
+		kind    string
+		payload string
+		want    []string
+		invalid bool
+	}{
+		{
+			name:    "pix válido",
+			kind:    EventKindPix,
+			payload: `{"pix":[{"endToEndId":"E12345678202009091221abcdef12345","valor":"110.00","horario":"2020-09-09T20:15:00.358Z"}]}`,
+			want:    []string{"pix:E12345678202009091221abcdef12345"},
+		},
+		{
+			name:    "pix com campo desconhecido",
+			kind:    EventKindPix,
+			payload: `{"pix":[{"endToEndId":"E12345678202009091221abcdef12345","valor":"110.00","horario":"2020-09-09T20:15:00.358Z","campoNovo":{"a":1}}]}`,
+			want:    []string{"pix:E12345678202009091221abcdef12345"},
+			invalid: true,
+		},
+		{
+			name:    "devolução com valor fora do formato",
+			kind:    EventKindPix,
+			payload: `{"pix":[{"endToEndId":"E12345678202009091221abcdef12345","valor":110,"devolucoes":[{"id":"1","rtrId":"D12345678202009091221abcdf098765","valor":7.89,"status":"DEVOLVIDO"}]}]}`,
+			want:    []string{"pix:E12345678202009091221abcdef12345", "pix:E12345678202009091221abcdef12345:devolucao:D12345678202009091221abcdf098765:DEVOLVIDO"},
+			invalid: true,
+		},
+		{
+			name:    "rec com status desconhecido",
+			kind:    EventKindRec,
+			payload: `{"rec":[{"idRec":"RR1234567820240115abcdefghijk","status":"SUSPENSA"}]}`,
+			want:    []string{"rec:RR1234567820240115abcdefghijk:SUSPENSA"},
+			invalid: true,
+		},
+		{
+			name:    "cobr sem campos obrigatórios",
+			kind:    EventKindCobr,
+			payload: `{"cobr":[{"txid":"3136957d93134f2184b369e8f1c0729d","status":"ATIVA","extra":true}]}`,
+			want:    []string{"cobr:3136957d93134f2184b369e8f1c0729d:ATIVA"},
+			invalid: true,
+		},
+		{
+			name:    "itens sem identificador",
+			kind:    EventKindPix,
+			payload: `{"pix":[{"valor":"1.00"}]}`,
+			invalid: true,
+		},
+		{
+			name:    "lista com tipo errado",
+			kind:    EventKindRec,
+			payload: `{"rec":{"idRec":"RR1234567820240115abcdefghijk"}}`,
+			invalid: true,
+		},
+		{
+			name:    "teste da EFI",
+			kind:    EventKindTest,
+			payload: `{"evento":"teste_webhook"}`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := EventKeys(tc.kind, []byte(tc.payload)); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("EventKeys = %q, esperado %q", got, tc.want)
+			}
+			if err := ValidateEvent(tc.kind, []byte(tc.payload)); (err != nil) != tc.invalid {
+				t.Errorf("ValidateEvent = %v, esperado inválido = %v", err, tc.invalid)
+			}
+		})
+	}
+}
+
+func TestRecordDeduplicatesInvalidPayloads(t *testing.T) {
+	store, err := OpenStore(filepath.Join(t.TempDir(), "pix.db"))
+	if err != nil {
+		t.Fatalf("OpenStore: %v", err)
+	}
+	defer store.Close()
+	events := NewEventStore(store)
+
+	payload := []byte(`{"pix":[{"endToEndId":"E12345678202009091221abcdef12345","valor":"110.00","horario":"2020-09-09T20:15:00.358Z","campoNovo":true}]}`)
+	deliver := func() WebhookEvent {
+		t.Helper()
+		event, err := events.Record(WebhookEvent{
+			Env:     "sandbox",
+			Kind:    EventKindPix,
+			Payload: payload,
+			Invalid: "campo desconhecido",
+			Keys:    EventKeys(EventKindPix, payload),
+		})
+		if err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+		return event
+	}
+
+	first := deliver()
+	if first.Duplicate || len(first.NewKeys) != 1 {
+		t.Fatalf("primeira entrega: duplicate = %v, new_keys = %q", first.Duplicate, first.NewKeys)
+	}
+
+	second := deliver()
+	if !second.Duplicate || len(second.NewKeys) != 0 {
+		t.Fatalf("reentrega: duplicate = %v, new_keys = %q", second.Duplicate, second.NewKeys)
+	}
+
+	stats, err := events.Stats("sandbox")
+	if err != nil {
+		t.Fatalf("Stats: %v", err)
+	}
+	want := EventStats{Deliveries: 2, LogicalEvents: 1, Duplicates: 1, Invalid: 2}
+	if stats != want {
+		t.Errorf("Stats = %+v, esperado %+v", stats, want)
+	}
+}
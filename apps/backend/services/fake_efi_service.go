@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"pix_cli/models"
+)
+
+// FakeEFIService é uma implementação em memória de EFIClient. Ela guarda o
+// estado de um webhook por tipo e responde com os mesmos códigos HTTP da API
+// EFI, permitindo exercitar controllers e servidor sem rede nem certificados.
+type FakeEFIService struct {
+	mu       sync.Mutex
+	webhooks map[models.WebhookType]fakeWebhook
+}
+
+type fakeWebhook struct {
+	URL     string
+	Criacao time.Time
+}
+
+var _ EFIClient = (*FakeEFIService)(nil)
+
+func NewFakeEFIService() *FakeEFIService {
+	return &FakeEFIService{
+		webhooks: make(map[models.WebhookType]fakeWebhook),
+	}
+}
+
+func (f *FakeEFIService) ExecuteWebhookCommand(cmd *models.WebhookCommand) (*models.WebhookResponse, error) {
+	return f.ExecuteWebhookCommandContext(context.Background(), cmd)
+}
+
+func (f *FakeEFIService) ExecuteWebhookCommandContext(ctx context.Context, cmd *models.WebhookCommand) (*models.WebhookResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if cmd.Type != models.WebhookTypeCharge && cmd.Type != models.WebhookTypeRecurrence {
+		return nil, fmt.Errorf("tipo de webhook não suportado: %s", cmd.Type)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch cmd.Action {
+	case "config":
+		if cmd.URL == "" {
+			return nil, &EFIError{
+				StatusCode: 400,
+				Nome:       "json_invalido",
+				Mensagem:   "Valores ou tipos de campo inválidos",
+				Violations: []EFIViolation{{Chave: "obrigatorio", Caminho: ".webhookUrl", Mensagem: "não deve ser vazio"}},
+				Method:     "PUT",
+				Path:       fakePath(cmd.Type),
+			}
+		}
+		f.webhooks[cmd.Type] = fakeWebhook{URL: cmd.URL, Criacao: time.Now().UTC()}
+		return &models.WebhookResponse{
+			Code:    200,
+			Message: "Comando executado com sucesso",
+			Data: map[string]interface{}{
+				"webhookUrl": cmd.URL,
+			},
+		}, nil
+	case "list":
+		webhook, ok := f.webhooks[cmd.Type]
+		if !ok {
+			return nil, fakeNotFound("GET", cmd.Type)
+		}
+		return &models.WebhookResponse{
+			Code:    200,
+			Message: "Comando executado com sucesso",
+			Data: map[string]interface{}{
+				"webhookUrl": webhook.URL,
+				"criacao":    webhook.Criacao.Format(time.RFC3339),
+			},
+		}, nil
+	case "delete":
+		if _, ok := f.webhooks[cmd.Type]; !ok {
+			return nil, fakeNotFound("DELETE", cmd.Type)
+		}
+		delete(f.webhooks, cmd.Type)
+		return &models.WebhookResponse{
+			Code:    204,
+			Message: "Comando executado com sucesso",
+			Data:    map[string]interface{}{},
+		}, nil
+	default:
+		return nil, fmt.Errorf("ação não suportada para webhook de %s: %s", cmd.Type, cmd.Action)
+	}
+}
+
+func (f *FakeEFIService) ConfigWebhook(webhookType models.WebhookType, webhookURL string) (*models.WebhookResponse, error) {
+	return f.ConfigWebhookContext(context.Background(), webhookType, webhookURL)
+}
+
+func (f *FakeEFIService) ConfigWebhookContext(ctx context.Context, webhookType models.WebhookType, webhookURL string) (*models.WebhookResponse, error) {
+	return f.ExecuteWebhookCommandContext(ctx, &models.WebhookCommand{
+		Type:   webhookType,
+		Action: "config",
+		URL:    webhookURL,
+	})
+}
+
+func (f *FakeEFIService) ListWebhook(webhookType models.WebhookType) (*models.WebhookResponse, error) {
+	return f.ListWebhookContext(context.Background(), webhookType)
+}
+
+func (f *FakeEFIService) ListWebhookContext(ctx context.Context, webhookType models.WebhookType) (*models.WebhookResponse, error) {
+	return f.ExecuteWebhookCommandContext(ctx, &models.WebhookCommand{
+		Type:   webhookType,
+		Action: "list",
+	})
+}
+
+func (f *FakeEFIService) DeleteWebhook(webhookType models.WebhookType) (*models.WebhookResponse, error) {
+	return f.DeleteWebhookContext(context.Background(), webhookType)
+}
+
+func (f *FakeEFIService) DeleteWebhookContext(ctx context.Context, webhookType models.WebhookType) (*models.WebhookResponse, error) {
+	return f.ExecuteWebhookCommandContext(ctx, &models.WebhookCommand{
+		Type:   webhookType,
+		Action: "delete",
+	})
+}
+
+func fakeNotFound(method string, webhookType models.WebhookType) *EFIError {
+	return &EFIError{
+		StatusCode: 404,
+		Nome:       "webhook_nao_encontrado",
+		Mensagem:   "Webhook não encontrado",
+		Method:     method,
+		Path:       fakePath(webhookType),
+	}
+}
+
+// fakePath retorna o caminho da API EFI equivalente ao tipo de webhook
+func fakePath(webhookType models.WebhookType) string {
+	if webhookType == models.WebhookTypeRecurrence {
+		return "/v2/webhookrec"
+	}
+	return "/v2/webhookcobr"
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// WebhookCAPath retorna o arquivo com a cadeia da CA que assina o certificado
+// de cliente usado pela EFI ao entregar notificações. Configurável por
+// ambiente em EFI_WEBHOOK_CA_FILE_SANDBOX / EFI_WEBHOOK_CA_FILE_PRODUCTION
+// (ou EFI_WEBHOOK_CA_FILE); o padrão é ./certs/efi_webhook_ca_<env>.pem.
+func WebhookCAPath(env string) string {
+	if path := envSetting("EFI_WEBHOOK_CA_FILE", env); path != "" {
+		return path
+	}
+	return filepath.Join("./certs", fmt.Sprintf("efi_webhook_ca_%s.pem", env))
+}
+
+// LoadWebhookClientCAs carrega a CA da EFI de cada ambiente. Ambientes sem
+// arquivo ficam de fora do mapa: notificações com certificado desses
+// ambientes não podem ser verificadas e são recusadas.
+func LoadWebhookClientCAs() (map[string]*x509.CertPool, error) {
+	return loadCAPools(WebhookCAPath)
+}
+
+// loadCAPools carrega, para cada ambiente, os certificados PEM do arquivo
+// indicado por path. Ambientes sem arquivo ficam de fora do mapa.
+func loadCAPools(path func(env string) string) (map[string]*x509.CertPool, error) {
+	pools := make(map[string]*x509.CertPool)
+
+	for _, env := range KnownEnvironments {
+		path := path(env)
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("erro ao ler CA da EFI (%s): %v", path, err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("nenhum certificado PEM válido na CA da EFI (%s)", path)
+		}
+		pools[env] = pool
+	}
+
+	return pools, nil
+}
+
+// VerifyWebhookClient valida a cadeia apresentada pela EFI contra a CA do
+// ambiente; o primeiro certificado é o da EFI e os demais, intermediários
+func VerifyWebhookClient(pool *x509.CertPool, chain []*x509.Certificate, now time.Time) error {
+	if pool == nil {
+		return fmt.Errorf("CA da EFI não configurada para o ambiente")
+	}
+	if len(chain) == 0 {
+		return fmt.Errorf("certificado de cliente ausente")
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	_, err := chain[0].Verify(x509.VerifyOptions{
+		Roots:         pool,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		return fmt.Errorf("certificado de cliente inválido: %v", err)
+	}
+	return nil
+}
+
+// LoadReceiverAllowlist lê as faixas de IP de saída da EFI aceitas pelo
+// receptor em PIX_RECEIVER_ALLOWED_IPS_SANDBOX /
+// PIX_RECEIVER_ALLOWED_IPS_PRODUCTION (ou PIX_RECEIVER_ALLOWED_IPS), como IPs
+// ou CIDRs separados por vírgula. Lista vazia não restringe.
+func LoadReceiverAllowlist(env string) ([]*net.IPNet, error) {
+	var allowlist []*net.IPNet
+
+	for _, item := range strings.Split(envSetting("PIX_RECEIVER_ALLOWED_IPS", env), ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("PIX_RECEIVER_ALLOWED_IPS: IP inválido: %s", item)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			allowlist = append(allowlist, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, fmt.Errorf("PIX_RECEIVER_ALLOWED_IPS: faixa inválida: %s", item)
+		}
+		allowlist = append(allowlist, network)
+	}
+
+	return allowlist, nil
+}
+
+// AllowedIP informa se o IP pertence a alguma faixa da lista
+func AllowedIP(allowlist []*net.IPNet, ip net.IP) bool {
+	for _, network := range allowlist {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,221 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Este arquivo concentra o que pode sair nos logs e respostas sobre segredos:
+// credenciais, tokens, cabeçalhos de autenticação e URLs com credenciais.
+
+// MaskSecret oculta um segredo mantendo apenas os últimos 4 caracteres, e
+// nenhum quando o segredo é curto demais para isso ser seguro
+func MaskSecret(secret string) string {
+	if secret == "" {
+		return ""
+	}
+
+	runes := []rune(secret)
+	if len(runes) < 12 {
+		return strings.Repeat("•", 8)
+	}
+	return strings.Repeat("•", 8) + string(runes[len(runes)-4:])
+}
+
+// SecretFingerprint identifica um segredo sem revelá-lo, permitindo comparar
+// se dois ambientes ou duas versões usam o mesmo valor
+func SecretFingerprint(secret string) string {
+	if secret == "" {
+		return ""
+	}
+
+	sum := sha256.Sum256([]byte(secret))
+	return "sha256:" + hex.EncodeToString(sum[:8])
+}
+
+// redactedPlaceholder substitui valores sensíveis nos logs; em URLs usa-se a
+// forma sem colchetes para não ser percentual-codificada
+const (
+	redactedPlaceholder    = "[REDACTED]"
+	redactedURLPlaceholder = "REDACTED"
+)
+
+// sensitiveKeys são campos JSON e parâmetros de query que nunca vão para o
+// log em texto puro (comparados sem diferenciar maiúsculas)
+var sensitiveKeys = map[string]bool{
+	"access_token":         true,
+	"refresh_token":        true,
+	"token":                true,
+	"client_secret":        true,
+	"clientsecret":         true,
+	"password":             true,
+	"certificate_password": true,
+	"certificatepassword":  true,
+	"secret":               true,
+	"hmac":                 true,
+	"signature":            true,
+	"authorization":        true,
+	"api_key":              true,
+	"key":                  true,
+}
+
+// sensitiveHeaders são cabeçalhos HTTP cujo valor é sempre ocultado
+var sensitiveHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+
+// isSensitiveKey indica se o nome do campo ou parâmetro carrega um segredo
+func isSensitiveKey(key string) bool {
+	return sensitiveKeys[strings.ToLower(key)]
+}
+
+// Format garante que Credentials nunca seja impresso com o segredo em claro,
+// inclusive via %v, %+v e %#v
+func (c Credentials) Format(f fmt.State, verb rune) {
+	fmt.Fprint(f, c.String())
+}
+
+// String descreve as credenciais sem o client_secret e a senha do certificado
+func (c Credentials) String() string {
+	return fmt.Sprintf("{ClientID:%s ClientSecret:%s Sandbox:%v Env:%s Certificate:%s CertificatePassword:%s CertificateKey:%s}",
+		c.ClientID, redactIfSet(c.ClientSecret), c.Sandbox, c.Env, c.Certificate, redactIfSet(c.CertificatePassword), c.CertificateKey)
+}
+
+// LogValue garante o mesmo para os handlers do log/slog, inclusive o JSON,
+// que de outra forma serializaria as tags json com o client_secret
+func (c Credentials) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("client_id", c.ClientID),
+		slog.String("client_secret", redactIfSet(c.ClientSecret)),
+		slog.Bool("sandbox", c.Sandbox),
+		slog.String("env", c.Env),
+		slog.String("certificate", c.Certificate),
+		slog.String("certificate_password", redactIfSet(c.CertificatePassword)),
+		slog.String("certificate_key", c.CertificateKey),
+	)
+}
+
+// redactIfSet oculta um valor preenchido e mantém vazio o que não foi informado
+func redactIfSet(value string) string {
+	if value == "" {
+		return ""
+	}
+	return redactedPlaceholder
+}
+
+// RedactToken descreve um token de acesso sem revelá-lo
+func RedactToken(token string) string {
+	if token == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s(%d chars, %s)", redactedPlaceholder, len(token), SecretFingerprint(token))
+}
+
+// RedactHeaders retorna uma cópia dos cabeçalhos com Authorization e
+// similares ocultados
+func RedactHeaders(header http.Header) http.Header {
+	redacted := header.Clone()
+	for _, name := range sensitiveHeaders {
+		if redacted.Get(name) != "" {
+			redacted.Set(name, redactedPlaceholder)
+		}
+	}
+	return redacted
+}
+
+// RedactURL remove a senha de user:pass@host e oculta parâmetros de query
+// sensíveis, como o ?hmac= dos webhooks. Valores que não são URL voltam sem
+// alteração.
+func RedactURL(raw string) string {
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Host == "" {
+		return raw
+	}
+
+	if parsed.User != nil {
+		if _, hasPassword := parsed.User.Password(); hasPassword {
+			parsed.User = url.UserPassword(parsed.User.Username(), redactedURLPlaceholder)
+		}
+	}
+
+	if parsed.RawQuery != "" {
+		query := parsed.Query()
+		changed := false
+		for key := range query {
+			if isSensitiveKey(key) {
+				query.Set(key, redactedURLPlaceholder)
+				changed = true
+			}
+		}
+		if changed {
+			parsed.RawQuery = query.Encode()
+		}
+	}
+
+	return parsed.String()
+}
+
+var (
+	bearerPattern = regexp.MustCompile(`(?i)(bearer|basic)\s+[A-Za-z0-9\-._~+/=]+`)
+	urlPattern    = regexp.MustCompile(`https?://[^\s"'<>]+`)
+)
+
+// RedactText oculta tokens Bearer/Basic e credenciais em URLs de um texto livre
+func RedactText(text string) string {
+	text = bearerPattern.ReplaceAllString(text, "$1 "+redactedPlaceholder)
+	return urlPattern.ReplaceAllStringFunc(text, RedactURL)
+}
+
+// RedactBody oculta campos sensíveis de um corpo JSON (tokens, segredos e
+// URLs com credenciais). Corpos que não são JSON passam por RedactText.
+func RedactBody(body []byte) string {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return ""
+	}
+
+	var data interface{}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return RedactText(string(body))
+	}
+
+	var redacted bytes.Buffer
+	encoder := json.NewEncoder(&redacted)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(RedactValue(data)); err != nil {
+		return fmt.Sprintf("<%d bytes>", len(body))
+	}
+	return strings.TrimSpace(redacted.String())
+}
+
+// RedactValue percorre mapas, listas e strings decodificados de JSON
+// ocultando campos sensíveis e credenciais embutidas em URLs
+func RedactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			if isSensitiveKey(key) {
+				redacted[key] = redactedPlaceholder
+				continue
+			}
+			redacted[key] = RedactValue(item)
+		}
+		return redacted
+	case []interface{}:
+		redacted := make([]interface{}, len(v))
+		for i, item := range v {
+			redacted[i] = RedactValue(item)
+		}
+		return redacted
+	case string:
+		return RedactURL(v)
+	default:
+		return v
+	}
+}
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+)
+
+// KnownEnvironments são os ambientes da EFI suportados pela interface
+var KnownEnvironments = []string{"sandbox", "production"}
+
+// ServiceRegistry mantém um EFIService por ambiente (sandbox, production ou
+// qualquer outro que tenha credenciais em ./config). Cada serviço é criado
+// sob demanda na primeira utilização e reaproveitado nas seguintes, evitando
+// reler o .p12, refazer o handshake mTLS e o OAuth a cada requisição.
+type ServiceRegistry struct {
+	mu      sync.Mutex
+	entries map[string]*registryEntry
+	factory ClientFactory
+}
+
+// ClientFactory cria o cliente EFI de um ambiente
+type ClientFactory func(env string) (EFIClient, error)
+
+// registryEntry guarda o serviço de um ambiente. O mutex da entrada serializa
+// a criação do serviço sem bloquear os demais ambientes.
+type registryEntry struct {
+	mu      sync.Mutex
+	service EFIClient
+}
+
+// NewServiceRegistry cria um registro que constrói EFIService reais a partir
+// das credenciais e certificados de cada ambiente
+func NewServiceRegistry() *ServiceRegistry {
+	return NewServiceRegistryWithFactory(newEFIServiceForEnv)
+}
+
+// NewServiceRegistryWithFactory cria um registro que usa a factory informada
+// para construir os clientes, por exemplo FakeEFIService
+func NewServiceRegistryWithFactory(factory ClientFactory) *ServiceRegistry {
+	return &ServiceRegistry{
+		entries: make(map[string]*registryEntry),
+		factory: factory,
+	}
+}
+
+// newEFIServiceForEnv carrega as credenciais do ambiente e cria o EFIService
+func newEFIServiceForEnv(env string) (EFIClient, error) {
+	credentials, err := LoadCredentialsWithEnv(env)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao carregar credenciais: %v", err)
+	}
+
+	opts, err := LoadEFIOptions(env)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao carregar opções de conexão: %v", err)
+	}
+
+	service, err := NewEFIServiceWithOptions(credentials, opts)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao criar serviço EFI: %w", err)
+	}
+
+	return service, nil
+}
+
+// Get retorna o serviço do ambiente, criando-o se ainda não existir
+func (r *ServiceRegistry) Get(env string) (EFIClient, error) {
+	r.mu.Lock()
+	entry, ok := r.entries[env]
+	if !ok {
+		entry = &registryEntry{}
+		r.entries[env] = entry
+	}
+	r.mu.Unlock()
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+
+	if entry.service != nil {
+		return entry.service, nil
+	}
+
+	slog.Info("criando serviço EFI", "env", env)
+
+	service, err := r.factory(env)
+	if err != nil {
+		return nil, err
+	}
+
+	entry.service = service
+	return service, nil
+}
+
+// Loaded retorna os ambientes que já possuem um serviço criado, sem criar novos
+func (r *ServiceRegistry) Loaded() []string {
+	r.mu.Lock()
+	entries := make(map[string]*registryEntry, len(r.entries))
+	for env, entry := range r.entries {
+		entries[env] = entry
+	}
+	r.mu.Unlock()
+
+	envs := []string{}
+	for env, entry := range entries {
+		// Uma entrada bloqueada ainda está sendo criada e não conta como carregada
+		if !entry.mu.TryLock() {
+			continue
+		}
+		if entry.service != nil {
+			envs = append(envs, env)
+		}
+		entry.mu.Unlock()
+	}
+
+	sort.Strings(envs)
+	return envs
+}
+
+// Invalidate descarta o serviço em cache do ambiente. Deve ser chamado quando
+// as credenciais ou o certificado do ambiente forem alterados.
+func (r *ServiceRegistry) Invalidate(env string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.entries[env]; ok {
+		slog.Info("serviço EFI invalidado", "env", env)
+	}
+	delete(r.entries, env)
+}
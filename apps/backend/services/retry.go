@@ -0,0 +1,123 @@
+package services
+
+import (
+	"errors"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy define como ExecuteWebhookCommand repete chamadas que falharam
+// de forma transitória (erro de rede, 429 ou 5xx). Apenas operações
+// idempotentes (GET, PUT e DELETE nos webhooks) são repetidas.
+type RetryPolicy struct {
+	// MaxAttempts é o total de tentativas, incluindo a primeira. 1 desativa o retry.
+	MaxAttempts int
+	// BaseDelay é a espera antes da segunda tentativa; dobra a cada nova tentativa
+	BaseDelay time.Duration
+	// MaxDelay limita a espera entre tentativas, inclusive a pedida via Retry-After
+	MaxDelay time.Duration
+	// Jitter é a fração aleatória (0 a 1) aplicada sobre cada espera
+	Jitter float64
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   500 * time.Millisecond,
+		MaxDelay:    10 * time.Second,
+		Jitter:      0.2,
+	}
+}
+
+// backoff calcula a espera exponencial com jitter antes da próxima tentativa.
+// attempt é o número da tentativa que acabou de falhar (começando em 1).
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempt && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+
+	if p.Jitter > 0 {
+		spread := float64(delay) * p.Jitter
+		delay += time.Duration(spread * (2*rand.Float64() - 1))
+	}
+	if delay < 0 {
+		delay = 0
+	}
+
+	return delay
+}
+
+// nextDelay decide se a tentativa deve ser repetida e quanto esperar antes.
+// Um Retry-After maior que MaxDelay interrompe as tentativas.
+func (p RetryPolicy) nextDelay(method string, attempt int, resp *http.Response, err error) (time.Duration, bool) {
+	if attempt >= p.MaxAttempts || !isIdempotent(method) {
+		return 0, false
+	}
+
+	if err != nil {
+		return p.backoff(attempt), isTransportError(err)
+	}
+
+	if !isRetryableStatus(resp.StatusCode) {
+		return 0, false
+	}
+
+	if wait, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+		if p.MaxDelay > 0 && wait > p.MaxDelay {
+			return 0, false
+		}
+		return wait, true
+	}
+
+	return p.backoff(attempt), true
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func isRetryableStatus(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return status >= 500 && status != http.StatusNotImplemented
+}
+
+// isTransportError indica falha de rede ao executar a requisição (conexão, TLS, timeout)
+func isTransportError(err error) bool {
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+// parseRetryAfter interpreta o header Retry-After em segundos ou como data HTTP
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if at, err := http.ParseTime(value); err == nil {
+		wait := at.Sub(now)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+
+	return 0, false
+}
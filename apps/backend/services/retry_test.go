@@ -0,0 +1,145 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"pix_cli/models"
+)
+
+// fastRetry repete rápido, para os testes não dependerem do backoff real
+func fastRetry(maxAttempts int) RetryPolicy {
+	return RetryPolicy{MaxAttempts: maxAttempts, BaseDelay: 5 * time.Millisecond, MaxDelay: 3 * time.Second}
+}
+
+func listCharge(service *EFIService) (*models.WebhookResponse, error) {
+	return service.ExecuteWebhookCommandContext(context.Background(), &models.WebhookCommand{
+		Type:   models.WebhookTypeCharge,
+		Action: "list",
+	})
+}
+
+func TestRetryHonorsRetryAfter(t *testing.T) {
+	for _, status := range []int{http.StatusTooManyRequests, http.StatusServiceUnavailable} {
+		t.Run(http.StatusText(status), func(t *testing.T) {
+			standIn := newStandIn(t)
+			service := standIn.service(t, fastRetry(3))
+
+			standIn.sim.FailNext(1, status, time.Second)
+			start := time.Now()
+			_, err := listCharge(service)
+			elapsed := time.Since(start)
+
+			// Sem webhook configurado, a segunda tentativa recebe o 404 da EFI
+			if !IsNotFound(err) {
+				t.Fatalf("erro = %v; esperado 404 após repetir", err)
+			}
+			if got := standIn.Calls(); got != 2 {
+				t.Fatalf("chamadas = %d; esperado 2", got)
+			}
+			if elapsed < time.Second {
+				t.Fatalf("repetiu após %v; esperado esperar o Retry-After de 1s", elapsed)
+			}
+		})
+	}
+}
+
+func TestRetryAfterAboveMaxDelayStops(t *testing.T) {
+	standIn := newStandIn(t)
+	policy := fastRetry(3)
+	policy.MaxDelay = 500 * time.Millisecond
+	service := standIn.service(t, policy)
+
+	standIn.sim.FailNext(1, http.StatusTooManyRequests, 5*time.Second)
+	start := time.Now()
+	_, err := listCharge(service)
+
+	efiErr, ok := AsEFIError(err)
+	if !ok || efiErr.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("erro = %v; esperado o 429 da EFI", err)
+	}
+	if got := standIn.Calls(); got != 1 {
+		t.Fatalf("chamadas = %d; esperado 1", got)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("esperou %v com Retry-After acima de MaxDelay", elapsed)
+	}
+}
+
+func TestRetryStopsAtMaxAttempts(t *testing.T) {
+	standIn := newStandIn(t)
+	service := standIn.service(t, fastRetry(3))
+
+	standIn.sim.FailNext(10, http.StatusServiceUnavailable, 0)
+	_, err := listCharge(service)
+
+	efiErr, ok := AsEFIError(err)
+	if !ok || efiErr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("erro = %v; esperado o 503 da EFI", err)
+	}
+	if got := standIn.Calls(); got != 3 {
+		t.Fatalf("chamadas = %d; esperado 3 (MaxAttempts)", got)
+	}
+}
+
+func TestRetryDoesNotRepeatClientErrors(t *testing.T) {
+	standIn := newStandIn(t)
+	service := standIn.service(t, fastRetry(3))
+
+	_, err := service.ExecuteWebhookCommandContext(context.Background(), &models.WebhookCommand{
+		Type:   models.WebhookTypeCharge,
+		Action: "config",
+		URL:    "http://sem-https.example",
+	})
+	if efiErr, ok := AsEFIError(err); !ok || efiErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("erro = %v; esperado 400", err)
+	}
+	if got := standIn.Calls(); got != 1 {
+		t.Fatalf("chamadas = %d; esperado 1", got)
+	}
+}
+
+// Nenhuma operação de webhook usa POST; a recusa de métodos não idempotentes
+// é conferida direto na política
+func TestRetryOnlyIdempotentMethods(t *testing.T) {
+	policy := fastRetry(3)
+	resp := &http.Response{StatusCode: http.StatusServiceUnavailable, Header: http.Header{}}
+
+	for method, want := range map[string]bool{
+		http.MethodGet:    true,
+		http.MethodPut:    true,
+		http.MethodDelete: true,
+		http.MethodPost:   false,
+		http.MethodPatch:  false,
+	} {
+		if _, retry := policy.nextDelay(method, 1, resp, nil); retry != want {
+			t.Errorf("%s: retry = %v; esperado %v", method, retry, want)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 31, 12, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"", 0, false},
+		{"3", 3 * time.Second, true},
+		{"-1", 0, false},
+		{now.Add(10 * time.Second).Format(http.TimeFormat), 10 * time.Second, true},
+		{now.Add(-10 * time.Second).Format(http.TimeFormat), 0, true},
+		{"amanhã", 0, false},
+	}
+
+	for _, tc := range cases {
+		got, ok := parseRetryAfter(tc.value, now)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("parseRetryAfter(%q) = %v, %v; esperado %v, %v", tc.value, got, ok, tc.want, tc.ok)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Permission é uma operação da API sujeita a autorização
+type Permission string
+
+const (
+	PermWebhookRead       Permission = "webhook:read"
+	PermWebhookWrite      Permission = "webhook:write"
+	PermCredentialsRead   Permission = "credentials:read"
+	PermCredentialsWrite  Permission = "credentials:write"
+	PermCredentialsReveal Permission = "credentials:reveal"
+	PermCertificateRead   Permission = "certificate:read"
+	PermCertificateWrite  Permission = "certificate:write"
+	PermServiceReload     Permission = "service:reload"
+	PermUsersManage       Permission = "users:manage"
+	PermAuditRead         Permission = "audit:read"
+)
+
+// Papéis disponíveis. admin (RoleAdmin) pode tudo; operator administra
+// webhooks, credenciais e certificados dos dois ambientes e consulta a
+// auditoria; developer faz o mesmo só no sandbox e apenas consulta produção;
+// viewer só consulta.
+const (
+	RoleOperator  = "operator"
+	RoleDeveloper = "developer"
+	RoleViewer    = "viewer"
+)
+
+// anyEnv marca permissões que valem para qualquer ambiente
+const anyEnv = "*"
+
+var (
+	readPermissions = []Permission{PermWebhookRead, PermCredentialsRead, PermCertificateRead}
+
+	// Recarregar o serviço derruba os clientes em uso e relê credenciais e
+	// certificados do disco, por isso não é permissão de consulta
+	writePermissions = append([]Permission{PermWebhookWrite, PermCredentialsWrite, PermCertificateWrite, PermServiceReload}, readPermissions...)
+
+	// rolePermissions mapeia papel → ambiente → permissões concedidas
+	rolePermissions = map[string]map[string][]Permission{
+		RoleAdmin: {
+			anyEnv: append([]Permission{PermCredentialsReveal, PermUsersManage, PermAuditRead}, writePermissions...),
+		},
+		RoleOperator: {
+			anyEnv: append([]Permission{PermAuditRead}, writePermissions...),
+		},
+		RoleDeveloper: {
+			"sandbox":    writePermissions,
+			"production": readPermissions,
+		},
+		RoleViewer: {
+			anyEnv: readPermissions,
+		},
+	}
+)
+
+// Roles retorna os papéis válidos, em ordem alfabética
+func Roles() []string {
+	roles := make([]string, 0, len(rolePermissions))
+	for role := range rolePermissions {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+	return roles
+}
+
+// ValidRole indica se o papel existe
+func ValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// Authorize verifica se o papel tem a permissão no ambiente. O erro traz o
+// motivo da recusa para ser devolvido ao cliente.
+func Authorize(role string, perm Permission, env string) error {
+	envs, ok := rolePermissions[role]
+	if !ok {
+		return fmt.Errorf("papel %q desconhecido", role)
+	}
+
+	for _, key := range []string{anyEnv, env} {
+		for _, granted := range envs[key] {
+			if granted == perm {
+				return nil
+			}
+		}
+	}
+
+	if env == "" {
+		return fmt.Errorf("o papel %s não tem a permissão %s", role, perm)
+	}
+	return fmt.Errorf("o papel %s não tem a permissão %s no ambiente %s", role, perm, env)
+}
@@ -0,0 +1,263 @@
+package services
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// secretFileMagic identifica arquivos gravados pelo SecretBox. Arquivos sem
+// esse prefixo são lidos como texto puro, para manter compatíveis as
+// credenciais e certificados gravados antes da criptografia.
+var secretFileMagic = []byte("PIXENC1\n")
+
+// SecretBox criptografa arquivos sensíveis (credenciais, .p12 e chaves
+// privadas) com AES-256-GCM
+type SecretBox struct {
+	aead cipher.AEAD
+}
+
+var (
+	secretBoxOnce  sync.Once
+	secretBoxValue *SecretBox
+	secretBoxErr   error
+	plaintextWarn  sync.Once
+)
+
+// NewSecretBox cria o SecretBox a partir de uma chave de 32 bytes
+func NewSecretBox(key []byte) (*SecretBox, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("chave de criptografia deve ter 32 bytes, recebido %d", len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao criar cifra AES: %v", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao criar AES-GCM: %v", err)
+	}
+
+	return &SecretBox{aead: aead}, nil
+}
+
+// LoadSecretBox lê a chave de PIX_SECRET_KEY (base64) ou do arquivo indicado
+// em PIX_SECRET_KEY_FILE. Retorna nil sem erro quando nenhuma chave está
+// configurada; nesse caso os arquivos continuam sendo gravados em texto puro.
+func LoadSecretBox() (*SecretBox, error) {
+	encoded := strings.TrimSpace(os.Getenv("PIX_SECRET_KEY"))
+
+	if encoded == "" {
+		keyFile := os.Getenv("PIX_SECRET_KEY_FILE")
+		if keyFile == "" {
+			return nil, nil
+		}
+
+		data, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao ler PIX_SECRET_KEY_FILE: %v", err)
+		}
+		encoded = strings.TrimSpace(string(data))
+	}
+
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("chave de criptografia inválida (esperado base64): %v", err)
+	}
+
+	return NewSecretBox(key)
+}
+
+// GenerateSecretKey gera uma nova chave aleatória codificada em base64
+func GenerateSecretKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("erro ao gerar chave: %v", err)
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// defaultSecretBox carrega a chave configurada uma única vez por processo
+func defaultSecretBox() (*SecretBox, error) {
+	secretBoxOnce.Do(func() {
+		secretBoxValue, secretBoxErr = LoadSecretBox()
+	})
+	return secretBoxValue, secretBoxErr
+}
+
+// IsEncrypted indica se o conteúdo foi gravado pelo SecretBox
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, secretFileMagic)
+}
+
+// Seal criptografa o conteúdo, prefixando o identificador e o nonce
+func (b *SecretBox) Seal(plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("erro ao gerar nonce: %v", err)
+	}
+
+	out := append([]byte{}, secretFileMagic...)
+	out = append(out, nonce...)
+	return b.aead.Seal(out, nonce, plaintext, secretFileMagic), nil
+}
+
+// Open descriptografa um conteúdo gravado por Seal
+func (b *SecretBox) Open(data []byte) ([]byte, error) {
+	if !IsEncrypted(data) {
+		return nil, fmt.Errorf("conteúdo não está criptografado")
+	}
+
+	data = data[len(secretFileMagic):]
+	if len(data) < b.aead.NonceSize() {
+		return nil, fmt.Errorf("conteúdo criptografado truncado")
+	}
+
+	nonce, ciphertext := data[:b.aead.NonceSize()], data[b.aead.NonceSize():]
+	plaintext, err := b.aead.Open(nil, nonce, ciphertext, secretFileMagic)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao descriptografar (chave incorreta ou arquivo corrompido)")
+	}
+	return plaintext, nil
+}
+
+// ReadSecretFile lê um arquivo sensível, descriptografando-o se necessário.
+// Arquivos em texto puro são retornados como estão.
+func ReadSecretFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return openSecret(data, path)
+}
+
+// openSecret descriptografa um conteúdo gravado por sealSecret com a chave
+// configurada; conteúdo em texto puro volta como está. source identifica a
+// origem do conteúdo nas mensagens de erro.
+func openSecret(data []byte, source string) ([]byte, error) {
+	if !IsEncrypted(data) {
+		return data, nil
+	}
+
+	box, err := defaultSecretBox()
+	if err != nil {
+		return nil, err
+	}
+	if box == nil {
+		return nil, fmt.Errorf("%s está criptografado; configure PIX_SECRET_KEY ou PIX_SECRET_KEY_FILE", source)
+	}
+
+	plaintext, err := box.Open(data)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler %s: %v", source, err)
+	}
+	return plaintext, nil
+}
+
+// sealSecret criptografa o conteúdo com a chave configurada. Sem chave, o
+// conteúdo é mantido em texto puro (apenas com permissão 0600).
+func sealSecret(data []byte) ([]byte, error) {
+	box, err := defaultSecretBox()
+	if err != nil {
+		return nil, err
+	}
+
+	if box == nil {
+		plaintextWarn.Do(func() {
+			slog.Warn("PIX_SECRET_KEY não configurada; credenciais e certificados serão gravados sem criptografia")
+		})
+		return data, nil
+	}
+
+	return box.Seal(data)
+}
+
+// WriteSecretFile grava um arquivo sensível de forma atômica, criptografado
+// quando há chave configurada e sempre com permissão 0600
+func WriteSecretFile(path string, data []byte) error {
+	sealed, err := sealSecret(data)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(path, sealed, 0600)
+}
+
+// SecretFilePaths lista os arquivos sensíveis conhecidos: credenciais em
+// ./config e certificados .p12 e chaves privadas em ./certs, junto com as
+// cópias .bak guardadas na troca de certificado (InstallCertificateFiles),
+// que têm o mesmo conteúdo sensível. O armazenamento local (Store)
+// criptografa cada registro e é migrado por Store.Compact.
+func SecretFilePaths() ([]string, error) {
+	var paths []string
+	for _, pattern := range []string{
+		filepath.Join("./config", "credentials_*.json"),
+		filepath.Join("./certs", "*.p12"),
+		filepath.Join("./certs", "*.key"),
+	} {
+		for _, pattern := range []string{pattern, pattern + ".bak"} {
+			matches, err := filepath.Glob(pattern)
+			if err != nil {
+				return nil, err
+			}
+			paths = append(paths, matches...)
+		}
+	}
+	return paths, nil
+}
+
+// MigrateSecretFiles criptografa os arquivos informados que ainda estão em
+// texto puro e ajusta a permissão de todos para 0600. Retorna os arquivos
+// que foram criptografados.
+func MigrateSecretFiles(paths []string) ([]string, error) {
+	box, err := defaultSecretBox()
+	if err != nil {
+		return nil, err
+	}
+	if box == nil {
+		return nil, fmt.Errorf("configure PIX_SECRET_KEY ou PIX_SECRET_KEY_FILE antes de migrar")
+	}
+
+	var migrated []string
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return migrated, fmt.Errorf("erro ao ler %s: %v", path, err)
+		}
+
+		if IsEncrypted(data) {
+			// Confere se o arquivo abre com a chave atual antes de considerá-lo migrado
+			if _, err := box.Open(data); err != nil {
+				return migrated, fmt.Errorf("%s: %v", path, err)
+			}
+			if err := os.Chmod(path, 0600); err != nil {
+				return migrated, fmt.Errorf("erro ao ajustar permissões de %s: %v", path, err)
+			}
+			continue
+		}
+
+		sealed, err := box.Seal(data)
+		if err != nil {
+			return migrated, err
+		}
+		if err := writeFileAtomic(path, sealed, 0600); err != nil {
+			return migrated, err
+		}
+
+		slog.Info("arquivo criptografado", "path", path)
+		migrated = append(migrated, path)
+	}
+
+	return migrated, nil
+}
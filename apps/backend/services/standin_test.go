@@ -0,0 +1,83 @@
+package services
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"pix_cli/simulator"
+)
+
+// standIn é uma API EFI local para os testes: o handler do simulador atrás de
+// um servidor mTLS que conta as chamadas aos webhooks
+type standIn struct {
+	*httptest.Server
+	sim *simulator.Server
+
+	mu      sync.Mutex
+	calls   int
+	headers []http.Header
+}
+
+// newStandIn sobe o simulador em um httptest.Server com mTLS obrigatório
+func newStandIn(t *testing.T) *standIn {
+	t.Helper()
+
+	pki, err := simulator.NewPKI()
+	if err != nil {
+		t.Fatalf("NewPKI: %v", err)
+	}
+
+	s := &standIn{sim: simulator.NewServer(pki, simulator.Config{ClientID: "Client_Id_teste", ClientSecret: "Client_Secret_teste"})}
+	handler := s.sim.Handler()
+
+	s.Server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/v2/") {
+			s.mu.Lock()
+			s.calls++
+			s.headers = append(s.headers, r.Header.Clone())
+			s.mu.Unlock()
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	s.TLS = &tls.Config{
+		Certificates: []tls.Certificate{pki.Server},
+		ClientCAs:    pki.CAPool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}
+	s.StartTLS()
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+// Calls retorna quantas chamadas aos webhooks o stand-in recebeu
+func (s *standIn) Calls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls
+}
+
+// service cria um EFIService apontado para o stand-in
+func (s *standIn) service(t *testing.T, retry RetryPolicy) *EFIService {
+	t.Helper()
+
+	service, err := NewEFIServiceWithOptions(&Credentials{
+		ClientID:     "Client_Id_teste",
+		ClientSecret: "Client_Secret_teste",
+		Sandbox:      true,
+		Env:          "sandbox",
+	}, EFIOptions{
+		BaseURL:           s.URL,
+		RootCAs:           s.sim.PKI.CAPool,
+		ClientCertificate: &s.sim.PKI.Client,
+		Retry:             &retry,
+	})
+	if err != nil {
+		t.Fatalf("NewEFIServiceWithOptions: %v", err)
+	}
+	return service
+}
@@ -0,0 +1,517 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// Buckets do armazenamento local
+const (
+	bucketMeta            = "meta"
+	bucketUsers           = "users"
+	bucketAudit           = "audit"
+	bucketWebhookHistory  = "webhook_history"
+	bucketEvents          = "events"
+	bucketEventKeys       = "event_keys"
+	bucketWebhookSettings = "webhook_settings"
+)
+
+// storeOp é uma operação gravada no arquivo do Store
+type storeOp struct {
+	Op     string          `json:"op"`
+	Bucket string          `json:"bucket"`
+	Key    string          `json:"key"`
+	Value  json.RawMessage `json:"value,omitempty"`
+}
+
+// storeBatch é uma linha do arquivo: operações aplicadas de forma atômica
+type storeBatch struct {
+	Ops []storeOp `json:"ops"`
+}
+
+// Store é o armazenamento local do backend: um banco chave-valor embutido,
+// organizado em buckets e gravado em um único arquivo de log somente de
+// acréscimo. Cada linha é um lote de operações aplicado de forma atômica;
+// uma linha incompleta no fim do arquivo (queda durante a gravação) é
+// descartada na abertura. Com PIX_SECRET_KEY configurada, cada linha é
+// criptografada. Os dados ficam em memória e o arquivo é compactado quando
+// acumula muitas linhas obsoletas; os eventos recebidos são limitados pela
+// retenção (ver EventStore.Prune).
+type Store struct {
+	mu      sync.RWMutex
+	path    string
+	file    *os.File
+	size    int64
+	lines   int
+	seq     uint64
+	buckets map[string]map[string]json.RawMessage
+	// lastErr é a última falha de gravação, exposta por Health
+	lastErr error
+	// broken é a falha que deixou o arquivo diferente do estado em memória;
+	// enquanto estiver preenchida, novas gravações são recusadas
+	broken error
+}
+
+// syncFile força a gravação do arquivo no disco; substituído nos testes
+var syncFile = (*os.File).Sync
+
+// StoreHealth descreve o estado do armazenamento local
+type StoreHealth struct {
+	Status        string         `json:"status"`
+	Path          string         `json:"path"`
+	SchemaVersion int            `json:"schema_version"`
+	Encrypted     bool           `json:"encrypted"`
+	SizeBytes     int64          `json:"size_bytes"`
+	Records       map[string]int `json:"records"`
+	Error         string         `json:"error,omitempty"`
+}
+
+// OpenStore abre (ou cria) o armazenamento no caminho informado e aplica as
+// migrações de esquema pendentes
+func OpenStore(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("erro ao criar diretório do armazenamento: %v", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao abrir armazenamento: %v", err)
+	}
+
+	s := &Store{
+		path:    path,
+		file:    file,
+		buckets: make(map[string]map[string]json.RawMessage),
+	}
+
+	if err := s.load(); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	if err := s.migrate(); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	if s.staleLocked() {
+		if err := s.Compact(); err != nil {
+			slog.Warn("erro ao compactar armazenamento", "path", path, "error", err)
+		}
+	}
+
+	return s, nil
+}
+
+// load lê o arquivo, aplicando cada lote em memória
+func (s *Store) load() error {
+	reader := bufio.NewReader(s.file)
+	var offset int64
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) > 0 {
+				// Lote incompleto de uma gravação interrompida
+				slog.Warn("descartando registro incompleto no fim do armazenamento", "path", s.path, "bytes", len(line))
+				if err := s.file.Truncate(offset); err != nil {
+					return fmt.Errorf("erro ao descartar registro incompleto: %v", err)
+				}
+			}
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("erro ao ler armazenamento: %v", err)
+		}
+
+		batch, err := s.decodeLine(bytes.TrimSpace(line))
+		if err != nil {
+			return fmt.Errorf("armazenamento %s corrompido na posição %d: %v", s.path, offset, err)
+		}
+		s.apply(batch.Ops)
+
+		offset += int64(len(line))
+		s.lines++
+	}
+
+	if _, err := s.file.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("erro ao posicionar armazenamento: %v", err)
+	}
+	s.size = offset
+	return nil
+}
+
+// decodeLine decodifica uma linha, descriptografando-a se necessário
+func (s *Store) decodeLine(line []byte) (storeBatch, error) {
+	var batch storeBatch
+
+	if len(line) > 0 && line[0] != '{' {
+		sealed, err := base64.StdEncoding.DecodeString(string(line))
+		if err != nil {
+			return batch, fmt.Errorf("registro inválido: %v", err)
+		}
+		if line, err = openSecret(sealed, s.path); err != nil {
+			return batch, err
+		}
+	}
+
+	if err := json.Unmarshal(line, &batch); err != nil {
+		return batch, fmt.Errorf("registro inválido: %v", err)
+	}
+	return batch, nil
+}
+
+// encodeLine serializa um lote como uma linha, criptografada quando há chave
+func encodeLine(batch storeBatch) ([]byte, error) {
+	data, err := json.Marshal(batch)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao serializar registro: %v", err)
+	}
+
+	sealed, err := sealSecret(data)
+	if err != nil {
+		return nil, err
+	}
+	if IsEncrypted(sealed) {
+		data = []byte(base64.StdEncoding.EncodeToString(sealed))
+	}
+
+	return append(data, '\n'), nil
+}
+
+// apply aplica as operações em memória; deve ser chamado com s.mu travado
+func (s *Store) apply(ops []storeOp) {
+	for _, op := range ops {
+		bucket := s.buckets[op.Bucket]
+		switch op.Op {
+		case "put":
+			if bucket == nil {
+				bucket = make(map[string]json.RawMessage)
+				s.buckets[op.Bucket] = bucket
+			}
+			bucket[op.Key] = op.Value
+		case "delete":
+			delete(bucket, op.Key)
+		}
+
+		// Chaves geradas por NextKey mantêm a sequência entre aberturas
+		if n, err := strconv.ParseUint(op.Key, 10, 64); err == nil && len(op.Key) == 20 && n > s.seq {
+			s.seq = n
+		}
+	}
+}
+
+// records conta os registros de todos os buckets
+func (s *Store) records() int {
+	total := 0
+	for _, bucket := range s.buckets {
+		total += len(bucket)
+	}
+	return total
+}
+
+// staleLocked indica que o arquivo acumulou linhas obsoletas o bastante para
+// compensar uma compactação; deve ser chamado com s.mu travado
+func (s *Store) staleLocked() bool {
+	return s.lines > 2*s.records()+100
+}
+
+// compactIfStale compacta o arquivo se ele tiver muitas linhas obsoletas,
+// como depois de uma remoção em massa
+func (s *Store) compactIfStale() error {
+	s.mu.RLock()
+	stale := s.staleLocked()
+	s.mu.RUnlock()
+
+	if !stale {
+		return nil
+	}
+	return s.Compact()
+}
+
+// StoreTx acumula as operações de uma transação aberta por Store.Update
+type StoreTx struct {
+	store   *Store
+	ops     []storeOp
+	pending map[string]map[string]json.RawMessage
+}
+
+// Put grava o valor (serializado em JSON) na chave do bucket
+func (tx *StoreTx) Put(bucket, key string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("erro ao serializar %s/%s: %v", bucket, key, err)
+	}
+
+	tx.ops = append(tx.ops, storeOp{Op: "put", Bucket: bucket, Key: key, Value: data})
+	tx.stage(bucket, key, data)
+	return nil
+}
+
+// Delete remove a chave do bucket
+func (tx *StoreTx) Delete(bucket, key string) {
+	tx.ops = append(tx.ops, storeOp{Op: "delete", Bucket: bucket, Key: key})
+	tx.stage(bucket, key, nil)
+}
+
+// Get lê a chave considerando as operações ainda não gravadas da transação
+func (tx *StoreTx) Get(bucket, key string, out interface{}) (bool, error) {
+	data, ok := tx.pending[bucket][key]
+	if !ok {
+		data, ok = tx.store.buckets[bucket][key]
+	}
+	if !ok || data == nil {
+		return false, nil
+	}
+
+	if err := json.Unmarshal(data, out); err != nil {
+		return false, fmt.Errorf("erro ao decodificar %s/%s: %v", bucket, key, err)
+	}
+	return true, nil
+}
+
+// NextKey gera uma chave crescente, usada nos buckets em ordem de inserção
+// (auditoria, histórico e eventos)
+func (tx *StoreTx) NextKey() string {
+	tx.store.seq++
+	return fmt.Sprintf("%020d", tx.store.seq)
+}
+
+func (tx *StoreTx) stage(bucket, key string, data json.RawMessage) {
+	if tx.pending[bucket] == nil {
+		tx.pending[bucket] = make(map[string]json.RawMessage)
+	}
+	tx.pending[bucket][key] = data
+}
+
+// Update executa fn em uma transação. As operações são gravadas em um único
+// registro somente se fn não retornar erro.
+func (s *Store) Update(fn func(tx *StoreTx) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx := &StoreTx{store: s, pending: make(map[string]map[string]json.RawMessage)}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if len(tx.ops) == 0 {
+		return nil
+	}
+
+	return s.commitLocked(tx.ops)
+}
+
+// commitLocked grava o lote no fim do arquivo e o aplica em memória; deve
+// ser chamado com s.mu travado
+func (s *Store) commitLocked(ops []storeOp) error {
+	line, err := encodeLine(storeBatch{Ops: ops})
+	if err != nil {
+		return err
+	}
+
+	if s.broken != nil {
+		return s.broken
+	}
+
+	if _, err := s.file.Write(line); err != nil {
+		return s.discardLocked(fmt.Errorf("erro ao gravar armazenamento: %v", err))
+	}
+	if err := syncFile(s.file); err != nil {
+		return s.discardLocked(fmt.Errorf("erro ao sincronizar armazenamento: %v", err))
+	}
+
+	s.size += int64(len(line))
+	s.lines++
+	s.lastErr = nil
+	s.apply(ops)
+	return nil
+}
+
+// discardLocked descarta o lote gravado em parte (ou sem confirmação do
+// disco), voltando o arquivo para s.size, para que ele não seja aplicado na
+// próxima abertura nem corrompa o próximo registro. Se nem isso for possível,
+// o Store recusa novas gravações até ser compactado ou reaberto.
+func (s *Store) discardLocked(cause error) error {
+	s.lastErr = cause
+
+	err := s.file.Truncate(s.size)
+	if err == nil {
+		_, err = s.file.Seek(s.size, io.SeekStart)
+	}
+	if err != nil {
+		s.broken = fmt.Errorf("%v; arquivo inconsistente com a memória: %v", cause, err)
+		s.lastErr = s.broken
+	}
+	return s.lastErr
+}
+
+// Put grava um único valor
+func (s *Store) Put(bucket, key string, value interface{}) error {
+	return s.Update(func(tx *StoreTx) error {
+		return tx.Put(bucket, key, value)
+	})
+}
+
+// Append grava o valor com uma chave crescente
+func (s *Store) Append(bucket string, value interface{}) error {
+	return s.Update(func(tx *StoreTx) error {
+		return tx.Put(bucket, tx.NextKey(), value)
+	})
+}
+
+// Delete remove uma única chave
+func (s *Store) Delete(bucket, key string) error {
+	return s.Update(func(tx *StoreTx) error {
+		tx.Delete(bucket, key)
+		return nil
+	})
+}
+
+// Get lê a chave do bucket em out; retorna false se ela não existir
+func (s *Store) Get(bucket, key string, out interface{}) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	data, ok := s.buckets[bucket][key]
+	if !ok {
+		return false, nil
+	}
+
+	if err := json.Unmarshal(data, out); err != nil {
+		return false, fmt.Errorf("erro ao decodificar %s/%s: %v", bucket, key, err)
+	}
+	return true, nil
+}
+
+// ForEach percorre o bucket em ordem de chave. fn não pode gravar no Store.
+func (s *Store) ForEach(bucket string, fn func(key string, value json.RawMessage) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]string, 0, len(s.buckets[bucket]))
+	for key := range s.buckets[bucket] {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if err := fn(key, s.buckets[bucket][key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Count retorna quantos registros há no bucket
+func (s *Store) Count(bucket string) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.buckets[bucket])
+}
+
+// Compact reescreve o arquivo apenas com os valores atuais, um registro por
+// linha, criptografado com a chave configurada no momento
+func (s *Store) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := make([]string, 0, len(s.buckets))
+	for name := range s.buckets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	lines := 0
+	for _, name := range names {
+		keys := make([]string, 0, len(s.buckets[name]))
+		for key := range s.buckets[name] {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			line, err := encodeLine(storeBatch{Ops: []storeOp{{Op: "put", Bucket: name, Key: key, Value: s.buckets[name][key]}}})
+			if err != nil {
+				return err
+			}
+			buf.Write(line)
+			lines++
+		}
+	}
+
+	if err := writeFileAtomic(s.path, buf.Bytes(), 0600); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(s.path, os.O_RDWR, 0600)
+	if err != nil {
+		s.lastErr = fmt.Errorf("erro ao reabrir armazenamento: %v", err)
+		return s.lastErr
+	}
+	if _, err := file.Seek(0, io.SeekEnd); err != nil {
+		file.Close()
+		return fmt.Errorf("erro ao posicionar armazenamento: %v", err)
+	}
+
+	s.file.Close()
+	s.file = file
+	s.size = int64(buf.Len())
+	s.lines = lines
+	s.broken = nil
+	return nil
+}
+
+// Health verifica se o arquivo continua acessível e se a última gravação
+// foi concluída
+func (s *Store) Health() StoreHealth {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	health := StoreHealth{
+		Status:        "online",
+		Path:          s.path,
+		SchemaVersion: s.schemaVersionLocked(),
+		Records:       make(map[string]int),
+	}
+
+	for name, bucket := range s.buckets {
+		if name != bucketMeta {
+			health.Records[name] = len(bucket)
+		}
+	}
+
+	if box, err := defaultSecretBox(); err == nil && box != nil {
+		health.Encrypted = true
+	}
+
+	info, err := os.Stat(s.path)
+	if err != nil {
+		health.Status, health.Error = "error", fmt.Sprintf("arquivo inacessível: %v", err)
+		return health
+	}
+	health.SizeBytes = info.Size()
+
+	if s.lastErr != nil {
+		health.Status, health.Error = "error", s.lastErr.Error()
+	}
+
+	return health
+}
+
+// Close fecha o arquivo do armazenamento
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
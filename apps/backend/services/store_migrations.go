@@ -0,0 +1,136 @@
+package services
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// storeMigration é uma alteração de esquema do armazenamento local. As
+// migrações rodam em ordem na abertura do Store, cada uma em uma transação
+// que também registra a nova versão do esquema.
+type storeMigration struct {
+	Version int
+	Name    string
+	Apply   func(tx *StoreTx, dir string) error
+}
+
+// storeMigrations lista as migrações do armazenamento. Novas migrações entram
+// sempre no fim, com a versão seguinte; as existentes não devem ser alteradas.
+var storeMigrations = []storeMigration{
+	{Version: 1, Name: "importa usuários de users.json", Apply: migrateLegacyUsers},
+	{Version: 2, Name: "importa o log de auditoria audit.log", Apply: migrateLegacyAudit},
+	{Version: 3, Name: "indexa eventos recebidos para deduplicação", Apply: migrateEventKeys},
+}
+
+// appliedMigration registra quando cada migração foi aplicada
+type appliedMigration struct {
+	Name      string    `json:"name"`
+	AppliedAt time.Time `json:"applied_at"`
+}
+
+// schemaVersionLocked retorna a versão do esquema; deve ser chamado com s.mu travado
+func (s *Store) schemaVersionLocked() int {
+	var version int
+	if data, ok := s.buckets[bucketMeta]["schema_version"]; ok {
+		json.Unmarshal(data, &version)
+	}
+	return version
+}
+
+// migrate aplica as migrações ainda não aplicadas
+func (s *Store) migrate() error {
+	dir := filepath.Dir(s.path)
+
+	for _, migration := range storeMigrations {
+		s.mu.RLock()
+		current := s.schemaVersionLocked()
+		s.mu.RUnlock()
+
+		if migration.Version <= current {
+			continue
+		}
+
+		err := s.Update(func(tx *StoreTx) error {
+			if err := migration.Apply(tx, dir); err != nil {
+				return err
+			}
+			if err := tx.Put(bucketMeta, fmt.Sprintf("migration_%04d", migration.Version), appliedMigration{
+				Name:      migration.Name,
+				AppliedAt: time.Now().UTC(),
+			}); err != nil {
+				return err
+			}
+			return tx.Put(bucketMeta, "schema_version", migration.Version)
+		})
+		if err != nil {
+			return fmt.Errorf("erro na migração %d (%s): %v", migration.Version, migration.Name, err)
+		}
+
+		slog.Info("migração do armazenamento aplicada", "version", migration.Version, "name", migration.Name)
+	}
+
+	return nil
+}
+
+// migrateLegacyUsers importa os usuários do antigo users.json, se existir
+func migrateLegacyUsers(tx *StoreTx, dir string) error {
+	data, err := ReadSecretFile(filepath.Join(dir, "users.json"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("erro ao ler users.json: %v", err)
+	}
+
+	var users []*User
+	if err := json.Unmarshal(data, &users); err != nil {
+		return fmt.Errorf("erro ao decodificar users.json: %v", err)
+	}
+
+	for _, user := range users {
+		var existing User
+		found, err := tx.Get(bucketUsers, user.Username, &existing)
+		if err != nil {
+			return err
+		}
+		if found {
+			continue
+		}
+		if err := tx.Put(bucketUsers, user.Username, user); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// migrateLegacyAudit importa as entradas do antigo audit.log, se existir
+func migrateLegacyAudit(tx *StoreTx, dir string) error {
+	file, err := os.Open(filepath.Join(dir, "audit.log"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("erro ao abrir audit.log: %v", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return fmt.Errorf("entrada inválida em audit.log: %v", err)
+		}
+		if err := tx.Put(bucketAudit, tx.NextKey(), entry); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
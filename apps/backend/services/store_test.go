@@ -0,0 +1,61 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Um lote cujo fsync falhou não pode ficar no arquivo sem estar na memória:
+// seria aplicado na próxima abertura e deslocaria o tamanho usado nos descartes
+func TestStoreDiscardsBatchWhenSyncFails(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pix.db")
+	store, err := OpenStore(path)
+	if err != nil {
+		t.Fatalf("OpenStore: %v", err)
+	}
+	defer func() { store.Close() }()
+
+	if err := store.Put(bucketMeta, "antes", "ok"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	before, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	syncFile = func(*os.File) error { return errors.New("disco indisponível") }
+	err = store.Put(bucketMeta, "falhou", "perdido")
+	syncFile = (*os.File).Sync
+	if err == nil {
+		t.Fatal("esperado erro de sincronização")
+	}
+
+	if health := store.Health(); health.Status != "error" {
+		t.Errorf("Health().Status = %q, esperado error", health.Status)
+	}
+	if after, err := os.Stat(path); err != nil || after.Size() != before.Size() {
+		t.Fatalf("tamanho após falha = %v (%v), esperado %d", after.Size(), err, before.Size())
+	}
+
+	if err := store.Put(bucketMeta, "depois", "ok"); err != nil {
+		t.Fatalf("Put após falha: %v", err)
+	}
+	if health := store.Health(); health.Status != "online" {
+		t.Errorf("Health().Status = %q após gravação bem-sucedida", health.Status)
+	}
+
+	store.Close()
+	if store, err = OpenStore(path); err != nil {
+		t.Fatalf("reabrir: %v", err)
+	}
+
+	var value string
+	for key, want := range map[string]bool{"antes": true, "falhou": false, "depois": true} {
+		found, err := store.Get(bucketMeta, key, &value)
+		if err != nil || found != want {
+			t.Errorf("Get(%s) = %v, %v; esperado %v", key, found, err, want)
+		}
+	}
+}
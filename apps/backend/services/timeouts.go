@@ -0,0 +1,74 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Timeouts define o prazo máximo de cada operação na EFI. O prazo cobre a
+// operação inteira, incluindo renovação de token e novas tentativas. Zero
+// desativa o prazo da operação, que passa a depender só do contexto recebido.
+type Timeouts struct {
+	Token  time.Duration
+	Config time.Duration
+	List   time.Duration
+	Delete time.Duration
+}
+
+func DefaultTimeouts() Timeouts {
+	return Timeouts{
+		Token:  30 * time.Second,
+		Config: 30 * time.Second,
+		List:   30 * time.Second,
+		Delete: 30 * time.Second,
+	}
+}
+
+// forAction retorna o prazo da ação de webhook (config, list ou delete)
+func (t Timeouts) forAction(action string) time.Duration {
+	switch action {
+	case "config":
+		return t.Config
+	case "list":
+		return t.List
+	case "delete":
+		return t.Delete
+	}
+	return 0
+}
+
+// withTimeout aplica o prazo ao contexto, se houver
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// loadTimeouts aplica as variáveis EFI_TIMEOUT_* sobre DefaultTimeouts
+func loadTimeouts(env string) (Timeouts, error) {
+	timeouts := DefaultTimeouts()
+
+	settings := map[string]*time.Duration{
+		"EFI_TIMEOUT_TOKEN":  &timeouts.Token,
+		"EFI_TIMEOUT_CONFIG": &timeouts.Config,
+		"EFI_TIMEOUT_LIST":   &timeouts.List,
+		"EFI_TIMEOUT_DELETE": &timeouts.Delete,
+	}
+
+	for name, target := range settings {
+		value := envSetting(name, env)
+		if value == "" {
+			continue
+		}
+
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout < 0 {
+			return timeouts, fmt.Errorf("%s inválido: %s", name, value)
+		}
+		*target = timeout
+	}
+
+	return timeouts, nil
+}
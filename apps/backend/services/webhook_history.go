@@ -0,0 +1,74 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// WebhookChange registra uma configuração ou remoção de webhook feita com
+// sucesso na EFI
+type WebhookChange struct {
+	Timestamp time.Time `json:"timestamp"`
+	Env       string    `json:"env"`
+	Type      string    `json:"type"`
+	// Action é "config", "delete" ou "rotate_secret"
+	Action     string `json:"action"`
+	WebhookURL string `json:"webhook_url,omitempty"`
+	Actor      string `json:"actor"`
+	RequestID  string `json:"request_id,omitempty"`
+}
+
+// WebhookHistory guarda o histórico de configuração dos webhooks no
+// armazenamento local
+type WebhookHistory struct {
+	store *Store
+}
+
+// NewWebhookHistory cria o histórico sobre o armazenamento local
+func NewWebhookHistory(store *Store) *WebhookHistory {
+	return &WebhookHistory{store: store}
+}
+
+// Record acrescenta uma alteração ao histórico. A URL é gravada sem segredos
+// de query (como ?hmac=).
+func (h *WebhookHistory) Record(change WebhookChange) error {
+	if change.Timestamp.IsZero() {
+		change.Timestamp = time.Now().UTC()
+	}
+	change.WebhookURL = RedactURL(change.WebhookURL)
+
+	if err := h.store.Append(bucketWebhookHistory, change); err != nil {
+		return fmt.Errorf("erro ao gravar histórico de webhooks: %v", err)
+	}
+	return nil
+}
+
+// List retorna as alterações do ambiente e tipo informados (vazios não
+// filtram), da mais recente para a mais antiga, limitadas a limit se > 0
+func (h *WebhookHistory) List(env, webhookType string, limit int) ([]WebhookChange, error) {
+	changes := []WebhookChange{}
+	err := h.store.ForEach(bucketWebhookHistory, func(key string, value json.RawMessage) error {
+		var change WebhookChange
+		if err := json.Unmarshal(value, &change); err != nil {
+			return fmt.Errorf("registro de histórico inválido: %v", err)
+		}
+		if (env == "" || change.Env == env) && (webhookType == "" || change.Type == webhookType) {
+			changes = append(changes, change)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// As chaves seguem a ordem de gravação; a mais recente vem primeiro
+	for i, j := 0, len(changes)-1; i < j; i, j = i+1, j-1 {
+		changes[i], changes[j] = changes[j], changes[i]
+	}
+	if limit > 0 && len(changes) > limit {
+		changes = changes[:limit]
+	}
+
+	return changes, nil
+}
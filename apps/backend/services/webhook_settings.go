@@ -0,0 +1,205 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// WebhookSecretParam é o parâmetro de query com o segredo acrescentado à URL
+// registrada na EFI
+const WebhookSecretParam = "hmac"
+
+// WebhookSettings guarda as opções com que cada webhook foi configurado na
+// EFI, usadas pelo receptor para decidir como autenticar as notificações
+type WebhookSettings struct {
+	Env  string `json:"env"`
+	Type string `json:"type"`
+	// WebhookURL é a URL informada pelo usuário, sem o segredo
+	WebhookURL string `json:"webhook_url"`
+	// SkipMTLS indica que o webhook foi configurado com x-skip-mtls-checking
+	SkipMTLS bool `json:"skip_mtls"`
+	// HMAC indica que a URL registrada leva o segredo em ?hmac=, exigido
+	// pelo receptor nas notificações sem certificado de cliente
+	HMAC   bool   `json:"hmac"`
+	Secret string `json:"secret,omitempty"`
+	// PreviousSecret continua aceito até PreviousExpiresAt, para que a troca
+	// do segredo não recuse notificações já em trânsito ou em reenvio
+	PreviousSecret    string    `json:"previous_secret,omitempty"`
+	PreviousExpiresAt time.Time `json:"previous_expires_at,omitempty"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// RegisteredURL é a URL a registrar na EFI, com o segredo quando houver
+func (w WebhookSettings) RegisteredURL() (string, error) {
+	if !w.HMAC {
+		return w.WebhookURL, nil
+	}
+	return WithWebhookSecret(w.WebhookURL, w.Secret)
+}
+
+// AcceptsSecret compara o segredo recebido com o atual e, dentro do período
+// de transição, com o anterior
+func (w WebhookSettings) AcceptsSecret(secret string, now time.Time) bool {
+	if secret == "" {
+		return false
+	}
+	if w.Secret != "" && hmac.Equal([]byte(secret), []byte(w.Secret)) {
+		return true
+	}
+	return w.PreviousSecret != "" && now.Before(w.PreviousExpiresAt) &&
+		hmac.Equal([]byte(secret), []byte(w.PreviousSecret))
+}
+
+// Rotate troca o segredo, mantendo o atual aceito durante grace
+func (w *WebhookSettings) Rotate(secret string, grace time.Duration, now time.Time) {
+	if w.Secret != "" && grace > 0 {
+		w.PreviousSecret, w.PreviousExpiresAt = w.Secret, now.Add(grace)
+	} else {
+		w.PreviousSecret, w.PreviousExpiresAt = "", time.Time{}
+	}
+	w.Secret = secret
+}
+
+// NewWebhookSecret gera um segredo aleatório para a URL do webhook
+func NewWebhookSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("erro ao gerar segredo do webhook: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// WithWebhookSecret acrescenta o segredo à URL em ?hmac=, substituindo um
+// segredo anterior
+func WithWebhookSecret(rawURL, secret string) (string, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" {
+		return "", fmt.Errorf("URL do webhook inválida: %s", RedactURL(rawURL))
+	}
+
+	query := parsed.Query()
+	query.Set(WebhookSecretParam, secret)
+	parsed.RawQuery = query.Encode()
+	return parsed.String(), nil
+}
+
+// StripWebhookSecret remove o ?hmac= da URL, se houver
+func StripWebhookSecret(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.RawQuery == "" {
+		return rawURL
+	}
+
+	query := parsed.Query()
+	query.Del(WebhookSecretParam)
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
+
+// WebhookSecretFromQuery extrai o segredo de uma notificação. A EFI acrescenta
+// /pix ao fim da URL registrada, então com o segredo na query o sufixo chega
+// colado ao valor (?hmac=<segredo>/pix).
+func WebhookSecretFromQuery(query url.Values) string {
+	return strings.TrimSuffix(query.Get(WebhookSecretParam), "/pix")
+}
+
+// LoadWebhookSecretGrace lê de PIX_WEBHOOK_SECRET_GRACE por quanto tempo o
+// segredo anterior continua aceito após uma troca (padrão: 1h)
+func LoadWebhookSecretGrace() (time.Duration, error) {
+	value := os.Getenv("PIX_WEBHOOK_SECRET_GRACE")
+	if value == "" {
+		return time.Hour, nil
+	}
+
+	grace, err := time.ParseDuration(value)
+	if err != nil || grace < 0 {
+		return 0, fmt.Errorf("PIX_WEBHOOK_SECRET_GRACE inválido: %s", value)
+	}
+	return grace, nil
+}
+
+// WebhookSettingsStore guarda as opções dos webhooks no armazenamento local
+type WebhookSettingsStore struct {
+	store *Store
+}
+
+// NewWebhookSettingsStore cria o repositório de opções dos webhooks
+func NewWebhookSettingsStore(store *Store) *WebhookSettingsStore {
+	return &WebhookSettingsStore{store: store}
+}
+
+func webhookSettingsKey(env, webhookType string) string {
+	return env + "/" + webhookType
+}
+
+// Save grava as opções do webhook, substituindo as anteriores
+func (w *WebhookSettingsStore) Save(settings WebhookSettings) error {
+	if settings.UpdatedAt.IsZero() {
+		settings.UpdatedAt = time.Now().UTC()
+	}
+
+	if err := w.store.Put(bucketWebhookSettings, webhookSettingsKey(settings.Env, settings.Type), settings); err != nil {
+		return fmt.Errorf("erro ao gravar opções do webhook: %v", err)
+	}
+	return nil
+}
+
+// Get retorna as opções do webhook, se houver
+func (w *WebhookSettingsStore) Get(env, webhookType string) (*WebhookSettings, bool, error) {
+	var settings WebhookSettings
+	found, err := w.store.Get(bucketWebhookSettings, webhookSettingsKey(env, webhookType), &settings)
+	if err != nil || !found {
+		return nil, false, err
+	}
+	return &settings, true, nil
+}
+
+// Delete remove as opções do webhook
+func (w *WebhookSettingsStore) Delete(env, webhookType string) error {
+	if err := w.store.Delete(bucketWebhookSettings, webhookSettingsKey(env, webhookType)); err != nil {
+		return fmt.Errorf("erro ao remover opções do webhook: %v", err)
+	}
+	return nil
+}
+
+// List retorna as opções dos webhooks do ambiente (vazio lista todos)
+func (w *WebhookSettingsStore) List(env string) ([]WebhookSettings, error) {
+	list := []WebhookSettings{}
+	err := w.store.ForEach(bucketWebhookSettings, func(key string, value json.RawMessage) error {
+		var settings WebhookSettings
+		if err := json.Unmarshal(value, &settings); err != nil {
+			return fmt.Errorf("opções de webhook inválidas: %v", err)
+		}
+		if env == "" || settings.Env == env {
+			list = append(list, settings)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// CheckSecret verifica o segredo de uma notificação entregue ao webhook
+// (env, webhookType). required indica que o webhook usa segredo; valid, que o
+// segredo recebido é o dele. Segredos de outros webhooks do ambiente não são
+// aceitos.
+func (w *WebhookSettingsStore) CheckSecret(env, webhookType, secret string, now time.Time) (required, valid bool) {
+	settings, found, err := w.Get(env, webhookType)
+	if err != nil {
+		// Sem conseguir ler as opções, a notificação é recusada
+		return true, false
+	}
+	if !found || !settings.HMAC {
+		return false, false
+	}
+	return true, settings.AcceptsSecret(secret, now)
+}
@@ -0,0 +1,152 @@
+package simulator
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// PKI reúne a CA gerada para o simulador e os certificados emitidos por ela:
+// o certificado do servidor (localhost) e o certificado de cliente usado pelo
+// backend no mTLS, no papel do .p12 emitido pela EFI.
+type PKI struct {
+	CACert    *x509.Certificate
+	CAPool    *x509.CertPool
+	Server    tls.Certificate
+	Client    tls.Certificate
+	caKey     *ecdsa.PrivateKey
+	caPEM     []byte
+	clientPEM []byte
+	clientKey []byte
+	serverPEM []byte
+	serverKey []byte
+}
+
+// NewPKI gera uma CA nova e emite os certificados de servidor e de cliente
+func NewPKI() (*PKI, error) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao gerar chave da CA: %v", err)
+	}
+
+	caTemplate := &x509.Certificate{
+		SerialNumber:          randomSerial(),
+		Subject:               pkix.Name{CommonName: "EFI Simulator CA", Organization: []string{"EFI Simulator"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(365 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao criar certificado da CA: %v", err)
+	}
+
+	caCert, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler certificado da CA: %v", err)
+	}
+
+	pki := &PKI{
+		CACert: caCert,
+		CAPool: x509.NewCertPool(),
+		caKey:  caKey,
+		caPEM:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}),
+	}
+	pki.CAPool.AddCert(caCert)
+
+	pki.serverPEM, pki.serverKey, err = pki.issue(&x509.Certificate{
+		Subject:     pkix.Name{CommonName: "localhost"},
+		DNSNames:    []string{"localhost"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1"), net.IPv6loopback},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if pki.Server, err = tls.X509KeyPair(pki.serverPEM, pki.serverKey); err != nil {
+		return nil, fmt.Errorf("erro ao montar certificado do servidor: %v", err)
+	}
+
+	pki.clientPEM, pki.clientKey, err = pki.issue(&x509.Certificate{
+		Subject:     pkix.Name{CommonName: "pix-auto-webhook", Organization: []string{"EFI Simulator"}},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if pki.Client, err = tls.X509KeyPair(pki.clientPEM, pki.clientKey); err != nil {
+		return nil, fmt.Errorf("erro ao montar certificado de cliente: %v", err)
+	}
+
+	return pki, nil
+}
+
+// issue emite um certificado folha assinado pela CA e o retorna em PEM junto com a chave
+func (p *PKI) issue(template *x509.Certificate) ([]byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("erro ao gerar chave: %v", err)
+	}
+
+	template.SerialNumber = randomSerial()
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(90 * 24 * time.Hour)
+	template.KeyUsage = x509.KeyUsageDigitalSignature
+
+	der, err := x509.CreateCertificate(rand.Reader, template, p.CACert, &key.PublicKey, p.caKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("erro ao emitir certificado %s: %v", template.Subject.CommonName, err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("erro ao serializar chave: %v", err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
+
+// WriteFiles grava em dir a CA (ca.pem) e o par de cliente (client.pem e
+// client-key.pem) para que o backend possa ser apontado para o simulador
+func (p *PKI) WriteFiles(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("erro ao criar diretório %s: %v", dir, err)
+	}
+
+	files := map[string][]byte{
+		"ca.pem":         p.caPEM,
+		"client.pem":     p.clientPEM,
+		"client-key.pem": p.clientKey,
+	}
+
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0600); err != nil {
+			return fmt.Errorf("erro ao gravar %s: %v", name, err)
+		}
+	}
+
+	return nil
+}
+
+func randomSerial() *big.Int {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 62))
+	if err != nil {
+		return big.NewInt(time.Now().UnixNano())
+	}
+	return serial
+}
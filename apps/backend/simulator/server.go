@@ -0,0 +1,314 @@
+package simulator
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config define as credenciais aceitas pelo simulador
+type Config struct {
+	ClientID     string
+	ClientSecret string
+	// TokenLifetime é o expires_in devolvido pelo /oauth/token
+	TokenLifetime time.Duration
+}
+
+// Server simula a API Pix da EFI (pix.api.efipay.com.br): exige mTLS com um
+// certificado de cliente emitido pela CA do simulador, emite tokens OAuth2 via
+// Basic Auth e mantém em memória os webhooks de cobrança e de recorrência.
+type Server struct {
+	PKI    *PKI
+	config Config
+
+	mu       sync.Mutex
+	tokens   map[string]time.Time
+	webhooks map[string]webhook
+	faults   fault
+
+	listener net.Listener
+	http     *http.Server
+}
+
+// fault descreve falhas injetadas nas próximas requisições de webhook
+type fault struct {
+	remaining  int
+	status     int
+	retryAfter time.Duration
+}
+
+type webhook struct {
+	URL     string
+	Criacao time.Time
+}
+
+// efiError segue o formato de erro da API EFI
+type efiError struct {
+	Nome     string         `json:"nome"`
+	Mensagem string         `json:"mensagem"`
+	Erros    []efiViolation `json:"erros,omitempty"`
+}
+
+type efiViolation struct {
+	Chave    string `json:"chave"`
+	Caminho  string `json:"caminho"`
+	Mensagem string `json:"mensagem"`
+}
+
+// webhookEndpoints mapeia os caminhos da API para o tipo de webhook
+var webhookEndpoints = map[string]string{
+	"/v2/webhookcobr": "charge",
+	"/v2/webhookrec":  "recurrence",
+}
+
+func NewServer(pki *PKI, config Config) *Server {
+	if config.TokenLifetime <= 0 {
+		config.TokenLifetime = time.Hour
+	}
+
+	return &Server{
+		PKI:      pki,
+		config:   config,
+		tokens:   make(map[string]time.Time),
+		webhooks: make(map[string]webhook),
+	}
+}
+
+// Start passa a atender em addr (ex: "127.0.0.1:0") com mTLS obrigatório
+func (s *Server) Start(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("erro ao abrir listener: %v", err)
+	}
+
+	s.listener = tls.NewListener(listener, &tls.Config{
+		Certificates: []tls.Certificate{s.PKI.Server},
+		ClientCAs:    s.PKI.CAPool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+	})
+	s.http = &http.Server{
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		if err := s.http.Serve(s.listener); err != nil && err != http.ErrServerClosed {
+			log.Printf("❌ [EFI Simulator] Erro no servidor: %v", err)
+		}
+	}()
+
+	log.Printf("🧪 [EFI Simulator] Atendendo em %s", s.URL())
+	return nil
+}
+
+// URL retorna a base URL do simulador, no formato esperado por EFIOptions.BaseURL
+func (s *Server) URL() string {
+	return "https://" + s.listener.Addr().String()
+}
+
+func (s *Server) Close() error {
+	if s.http == nil {
+		return nil
+	}
+	return s.http.Close()
+}
+
+// Handler expõe as rotas do simulador sem TLS, útil para embutir em outro servidor
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oauth/token", s.handleToken)
+	for path := range webhookEndpoints {
+		mux.HandleFunc(path, s.handleWebhook)
+	}
+	return mux
+}
+
+// FailNext faz as próximas n requisições de webhook falharem com o status
+// informado (ex: 429 ou 503), enviando Retry-After quando retryAfter > 0.
+// Serve para exercitar a política de retry do backend.
+func (s *Server) FailNext(n, status int, retryAfter time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults = fault{remaining: n, status: status, retryAfter: retryAfter}
+}
+
+// injectFault responde com a falha injetada, se houver alguma pendente
+func (s *Server) injectFault(w http.ResponseWriter) bool {
+	s.mu.Lock()
+	current := s.faults
+	if current.remaining > 0 {
+		s.faults.remaining--
+	}
+	s.mu.Unlock()
+
+	if current.remaining <= 0 {
+		return false
+	}
+
+	if current.retryAfter > 0 {
+		w.Header().Set("Retry-After", fmt.Sprintf("%d", int(current.retryAfter/time.Second)))
+	}
+
+	writeJSON(w, current.status, efiError{Nome: "falha_simulada", Mensagem: http.StatusText(current.status)})
+	return true
+}
+
+// handleToken emite um access token para credenciais válidas
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, efiError{Nome: "metodo_nao_permitido", Mensagem: "Método não permitido"})
+		return
+	}
+
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok || clientID != s.config.ClientID || clientSecret != s.config.ClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{
+			"error":             "invalid_client",
+			"error_description": "Invalid or inactive credentials",
+		})
+		return
+	}
+
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "client_credentials" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{
+			"error":             "unsupported_grant_type",
+			"error_description": "grant_type must be client_credentials",
+		})
+		return
+	}
+
+	token := randomToken()
+	s.mu.Lock()
+	s.tokens[token] = time.Now().Add(s.config.TokenLifetime)
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": token,
+		"token_type":   "Bearer",
+		"expires_in":   int(s.config.TokenLifetime / time.Second),
+		"scope":        "webhook.read webhook.write",
+	})
+}
+
+// handleWebhook atende PUT/GET/DELETE em /v2/webhookcobr e /v2/webhookrec
+func (s *Server) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	if s.injectFault(w) {
+		return
+	}
+
+	if !s.authorized(r) {
+		writeJSON(w, http.StatusUnauthorized, efiError{Nome: "nao_autorizado", Mensagem: "Token de acesso inválido ou expirado"})
+		return
+	}
+
+	kind := webhookEndpoints[r.URL.Path]
+
+	switch r.Method {
+	case http.MethodPut:
+		var body struct {
+			WebhookURL string `json:"webhookUrl"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeJSON(w, http.StatusBadRequest, efiError{Nome: "json_invalido", Mensagem: "Valores ou tipos de campo inválidos"})
+			return
+		}
+
+		if violation := validateWebhookURL(body.WebhookURL); violation != nil {
+			writeJSON(w, http.StatusBadRequest, efiError{
+				Nome:     "json_invalido",
+				Mensagem: "Valores ou tipos de campo inválidos",
+				Erros:    []efiViolation{*violation},
+			})
+			return
+		}
+
+		s.mu.Lock()
+		s.webhooks[kind] = webhook{URL: body.WebhookURL, Criacao: time.Now().UTC()}
+		s.mu.Unlock()
+
+		writeJSON(w, http.StatusOK, map[string]string{"webhookUrl": body.WebhookURL})
+	case http.MethodGet:
+		s.mu.Lock()
+		current, ok := s.webhooks[kind]
+		s.mu.Unlock()
+
+		if !ok {
+			writeJSON(w, http.StatusNotFound, efiError{Nome: "webhook_nao_encontrado", Mensagem: "Webhook não encontrado"})
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]string{
+			"webhookUrl": current.URL,
+			"criacao":    current.Criacao.Format(time.RFC3339),
+		})
+	case http.MethodDelete:
+		s.mu.Lock()
+		_, ok := s.webhooks[kind]
+		delete(s.webhooks, kind)
+		s.mu.Unlock()
+
+		if !ok {
+			writeJSON(w, http.StatusNotFound, efiError{Nome: "webhook_nao_encontrado", Mensagem: "Webhook não encontrado"})
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, efiError{Nome: "metodo_nao_permitido", Mensagem: "Método não permitido"})
+	}
+}
+
+// authorized valida o Bearer token recebido
+func (s *Server) authorized(r *http.Request) bool {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expiry, ok := s.tokens[token]
+	return ok && time.Now().Before(expiry)
+}
+
+// validateWebhookURL aplica as mesmas regras básicas da EFI para webhookUrl
+func validateWebhookURL(raw string) *efiViolation {
+	if raw == "" {
+		return &efiViolation{Chave: "obrigatorio", Caminho: ".webhookUrl", Mensagem: "não deve ser vazio"}
+	}
+
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Host == "" {
+		return &efiViolation{Chave: "formato_invalido", Caminho: ".webhookUrl", Mensagem: "não é uma URL válida"}
+	}
+
+	if parsed.Scheme != "https" {
+		return &efiViolation{Chave: "formato_invalido", Caminho: ".webhookUrl", Mensagem: "deve utilizar https"}
+	}
+
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func randomToken() string {
+	buf := make([]byte, 24)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
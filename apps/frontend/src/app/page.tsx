@@ -2,28 +2,32 @@
 
 import { Toaster } from '@/components/ui/toaster';
 import { useToast } from '@/hooks/use-toast';
-import { apiClient, WebhookConfig } from '@/lib/api';
+import { apiClient, authHeaders, WebhookConfig } from '@/lib/api';
 import { useEffect, useState } from 'react';
 import { Header } from '@/components/Header';
 import { ProductionWarning } from '@/components/ProductionWarning';
 import { StatsCards } from '@/components/StatsCards';
 import { WebhookList } from '@/components/WebhookList';
 import { EfiConfig } from '@/components/EfiConfig';
+import { LoginForm } from '@/components/LoginForm';
 
 export default function Home() {
   const [webhooks, setWebhooks] = useState<WebhookConfig[]>([])
   const [loading, setLoading] = useState(false)
   const [initialLoading, setInitialLoading] = useState(true)
+  const [authenticated, setAuthenticated] = useState(false)
   const [systemStatus, setSystemStatus] = useState({ backend: 'offline', efi: 'offline' })
   const [credentials, setCredentials] = useState({ sandbox: true })
   
   const [sandboxCredentials, setSandboxCredentials] = useState({
     clientId: '',
     clientSecret: '',
+    secretMask: '',
   })
   const [productionCredentials, setProductionCredentials] = useState({
     clientId: '',
     clientSecret: '',
+    secretMask: '',
   })
   const [certificateStatus, setCertificateStatus] = useState({
     sandbox: { exists: false, path: '' },
@@ -31,22 +35,33 @@ export default function Home() {
   })
   const { toast } = useToast()
 
+  const loadApp = async () => {
+    const storedEnv = typeof window !== 'undefined' ? window.localStorage.getItem('pix_env') : null
+    const initialEnv: 'sandbox' | 'production' = storedEnv === 'production' ? 'production' : 'sandbox'
+
+    setCredentials({ sandbox: initialEnv === 'sandbox' })
+    await apiClient.reloadService(initialEnv)
+
+    await Promise.all([
+      loadSystemStatus(),
+      loadWebhooksWithEnv(initialEnv),
+      loadCredentialsWithEnv(initialEnv),
+      checkCertificateStatusWithEnv(initialEnv)
+    ])
+  }
+
   useEffect(() => {
     const initializeApp = async () => {
       setInitialLoading(true)
       try {
-        const storedEnv = typeof window !== 'undefined' ? window.localStorage.getItem('pix_env') : null
-        const initialEnv: 'sandbox' | 'production' = storedEnv === 'production' ? 'production' : 'sandbox'
-
-        setCredentials({ sandbox: initialEnv === 'sandbox' })
-        await apiClient.reloadService(initialEnv)
-
-        await Promise.all([
-          loadSystemStatus(),
-          loadWebhooksWithEnv(initialEnv),
-          loadCredentialsWithEnv(initialEnv),
-          checkCertificateStatusWithEnv(initialEnv)
-        ])
+        const me = await apiClient.me()
+        if (!me.success) {
+          setAuthenticated(false)
+          return
+        }
+
+        setAuthenticated(true)
+        await loadApp()
       } catch (error) {
       } finally {
         setInitialLoading(false)
@@ -56,6 +71,22 @@ export default function Home() {
     initializeApp()
   }, [])
 
+  const handleLogin = async (username: string, password: string): Promise<string | null> => {
+    const response = await apiClient.login(username, password)
+    if (!response.success) {
+      return response.error || 'Erro ao entrar'
+    }
+
+    setAuthenticated(true)
+    setInitialLoading(true)
+    try {
+      await loadApp()
+    } finally {
+      setInitialLoading(false)
+    }
+    return null
+  }
+
   const loadSystemStatus = async () => {
     try {
       const response = await apiClient.getSystemStatus()
@@ -119,19 +150,24 @@ export default function Home() {
     try {
       const currentEnv = credentials.sandbox ? 'sandbox' : 'production'
       
-      const response = await fetch(`http://localhost:8081/api/load-credentials?env=${currentEnv}`)
+      const response = await fetch(`http://localhost:8081/api/load-credentials?env=${currentEnv}`, {
+        credentials: 'include',
+        headers: authHeaders(),
+      })
       if (response.ok) {
         const data = await response.json()
         if (data.success && data.data) {
           if (credentials.sandbox) {
             setSandboxCredentials({
               clientId: data.data.client_id || '',
-              clientSecret: data.data.client_secret || '',
+              clientSecret: '',
+              secretMask: data.data.client_secret_masked || '',
             })
           } else {
             setProductionCredentials({
               clientId: data.data.client_id || '',
-              clientSecret: data.data.client_secret || '',
+              clientSecret: '',
+              secretMask: data.data.client_secret_masked || '',
             })
           }
         }
@@ -145,7 +181,10 @@ export default function Home() {
     try {
       const currentEnv = credentials.sandbox ? 'sandbox' : 'production'
       
-      const response = await fetch(`http://localhost:8081/api/certificate-status?env=${currentEnv}`)
+      const response = await fetch(`http://localhost:8081/api/certificate-status?env=${currentEnv}`, {
+        credentials: 'include',
+        headers: authHeaders(),
+      })
       if (response.ok) {
         const data = await response.json()
         if (data.success) {
@@ -173,11 +212,11 @@ export default function Home() {
     }
   }
 
-  const handleConfigureWebhook = async (type: 'charge' | 'recurrence', url: string) => {
+  const handleConfigureWebhook = async (type: 'charge' | 'recurrence', url: string, options: { skipMtls: boolean; hmac: boolean }) => {
     setLoading(true)
     try {
       const currentEnv = credentials.sandbox ? 'sandbox' : 'production'
-      const response = await apiClient.configWebhook(type, url, currentEnv)
+      const response = await apiClient.configWebhook(type, url, currentEnv, options)
       
       if (response.success) {
         toast({
@@ -270,7 +309,7 @@ export default function Home() {
     const currentEnv = credentials.sandbox ? 'sandbox' : 'production'
     const currentCreds = credentials.sandbox ? sandboxCredentials : productionCredentials
     
-    if (!currentCreds.clientId || !currentCreds.clientSecret) {
+    if (!currentCreds.clientId || (!currentCreds.clientSecret && !currentCreds.secretMask)) {
       toast({
         title: '❌ Preencha Client ID e Client Secret',
         description: 'Por favor, preencha o Client ID e o Client Secret.',
@@ -282,13 +321,16 @@ export default function Home() {
     setLoading(true)
     try {
       const response = await fetch('http://localhost:8081/api/save-credentials', {
+        credentials: 'include',
         method: 'POST',
         headers: {
           'Content-Type': 'application/json',
+          ...authHeaders(),
         },
         body: JSON.stringify({
           clientId: currentCreds.clientId,
           clientSecret: currentCreds.clientSecret,
+          keepExistingSecret: !currentCreds.clientSecret,
           sandbox: credentials.sandbox,
           env: currentEnv
         }),
@@ -299,6 +341,7 @@ export default function Home() {
           title: `✅ Credenciais ${currentEnv} salvas com sucesso!`,
           description: `Credenciais ${currentEnv} salvas com sucesso.`,
         })
+        loadCredentialsWithEnv(currentEnv)
         loadSystemStatus()
       } else {
         const error = await response.text()
@@ -328,7 +371,9 @@ export default function Home() {
       formData.append('certificate', file)
       
       const response = await fetch(`http://localhost:8081/api/upload-certificate?env=${currentEnv}`, {
+        credentials: 'include',
         method: 'POST',
+        headers: authHeaders(),
         body: formData,
       })
       
@@ -442,19 +487,24 @@ export default function Home() {
 
   const loadCredentialsWithEnv = async (env: 'sandbox' | 'production') => {
     try {
-      const response = await fetch(`http://localhost:8081/api/load-credentials?env=${env}`)
+      const response = await fetch(`http://localhost:8081/api/load-credentials?env=${env}`, {
+        credentials: 'include',
+        headers: authHeaders(),
+      })
       if (response.ok) {
         const data = await response.json()
         if (data.success && data.data) {
           if (env === 'sandbox') {
             setSandboxCredentials({
               clientId: data.data.client_id || '',
-              clientSecret: data.data.client_secret || '',
+              clientSecret: '',
+              secretMask: data.data.client_secret_masked || '',
             })
           } else {
             setProductionCredentials({
               clientId: data.data.client_id || '',
-              clientSecret: data.data.client_secret || '',
+              clientSecret: '',
+              secretMask: data.data.client_secret_masked || '',
             })
           }
         }
@@ -466,7 +516,10 @@ export default function Home() {
 
   const checkCertificateStatusWithEnv = async (env: 'sandbox' | 'production') => {
     try {
-      const response = await fetch(`http://localhost:8081/api/certificate-status?env=${env}`)
+      const response = await fetch(`http://localhost:8081/api/certificate-status?env=${env}`, {
+        credentials: 'include',
+        headers: authHeaders(),
+      })
       if (response.ok) {
         const data = await response.json()
         if (data.success) {
@@ -505,6 +558,10 @@ export default function Home() {
     )
   }
 
+  if (!authenticated) {
+    return <LoginForm onLogin={handleLogin} />
+  }
+
   return (
     <div className="min-h-screen bg-gray-50">
       <div className="container mx-auto px-4 py-8">
@@ -10,8 +10,8 @@ import { Shield } from 'lucide-react'
 
 interface EfiConfigProps {
   credentials: { sandbox: boolean }
-  sandboxCredentials: { clientId: string; clientSecret: string }
-  productionCredentials: { clientId: string; clientSecret: string }
+  sandboxCredentials: { clientId: string; clientSecret: string; secretMask: string }
+  productionCredentials: { clientId: string; clientSecret: string; secretMask: string }
   certificateStatus: {
     sandbox: { exists: boolean; path: string }
     production: { exists: boolean; path: string }
@@ -36,7 +36,8 @@ export function EfiConfig({
 }: EfiConfigProps) {
   const currentCreds = credentials.sandbox ? sandboxCredentials : productionCredentials
   const currentCert = certificateStatus[credentials.sandbox ? 'sandbox' : 'production']
-  const isIncomplete = !currentCreds.clientId || !currentCreds.clientSecret || !currentCert.exists
+  const hasSecret = !!currentCreds.clientSecret || !!currentCreds.secretMask
+  const isIncomplete = !currentCreds.clientId || !hasSecret || !currentCert.exists
 
   const handleFileChange = (event: React.ChangeEvent<HTMLInputElement>) => {
     const file = event.target.files?.[0]
@@ -63,7 +64,7 @@ export function EfiConfig({
                 </div>
                 <ul className="mt-2 text-xs text-red-700 space-y-1">
                   {!currentCreds.clientId && <li>• Client ID não configurado</li>}
-                  {!currentCreds.clientSecret && <li>• Client Secret não configurado</li>}
+                  {!hasSecret && <li>• Client Secret não configurado</li>}
                   {!currentCert.exists && <li>• Certificado P12 não enviado</li>}
                 </ul>
               </div>
@@ -96,7 +97,7 @@ export function EfiConfig({
               <Input
                 id="clientSecret"
                 type="password"
-                placeholder="Seu Client Secret da EFI"
+                placeholder={currentCreds.secretMask ? `${currentCreds.secretMask} (deixe em branco para manter)` : 'Seu Client Secret da EFI'}
                 value={currentCreds.clientSecret}
                 onChange={(e) => {
                   const env = credentials.sandbox ? 'sandbox' : 'production'
@@ -109,7 +110,7 @@ export function EfiConfig({
           
           <Button 
             onClick={onSaveCredentials}
-            disabled={loading || !currentCreds.clientId || !currentCreds.clientSecret}
+            disabled={loading || !currentCreds.clientId || !hasSecret}
             className="w-full"
           >
             {loading ? 'Salvando...' : 'Salvar Credenciais'}
@@ -156,7 +157,7 @@ export function EfiConfig({
             variant="outline" 
             className="w-full justify-start"
             onClick={onTestConnection}
-            disabled={loading || !currentCreds.clientId || !currentCreds.clientSecret || !currentCert.exists}
+            disabled={loading || !currentCreds.clientId || !hasSecret || !currentCert.exists}
           >
             <div className="h-4 w-4 mr-2 animate-spin rounded-full border-2 border-gray-300 border-t-blue-600" />
             {loading ? 'Testando...' : 'Testar Conexão'}
@@ -41,19 +41,19 @@ interface WebhookConfig {
 
 interface WebhookListProps {
   webhooks: WebhookConfig[]
-  onConfigureWebhook: (type: 'charge' | 'recurrence', url: string) => void
+  onConfigureWebhook: (type: 'charge' | 'recurrence', url: string, options: { skipMtls: boolean; hmac: boolean }) => void
   onDeleteWebhook: (id: string) => void
   loading: boolean
 }
 
 export function WebhookList({ webhooks, onConfigureWebhook, onDeleteWebhook, loading }: WebhookListProps) {
   const [isConfiguring, setIsConfiguring] = useState(false)
-  const [newWebhook, setNewWebhook] = useState({ type: 'charge' as 'charge' | 'recurrence', url: '' })
+  const [newWebhook, setNewWebhook] = useState({ type: 'charge' as 'charge' | 'recurrence', url: '', skipMtls: false, hmac: false })
 
   const handleConfigure = () => {
     if (!newWebhook.url) return
-    onConfigureWebhook(newWebhook.type, newWebhook.url)
-    setNewWebhook({ type: 'charge', url: '' })
+    onConfigureWebhook(newWebhook.type, newWebhook.url, { skipMtls: newWebhook.skipMtls, hmac: newWebhook.hmac })
+    setNewWebhook({ type: 'charge', url: '', skipMtls: false, hmac: false })
     setIsConfiguring(false)
   }
 
@@ -130,6 +130,30 @@ export function WebhookList({ webhooks, onConfigureWebhook, onDeleteWebhook, loa
                       onChange={(e) => setNewWebhook(prev => ({ ...prev, url: e.target.value }))}
                     />
                   </div>
+                  <div className="flex items-start gap-2">
+                    <input
+                      id="webhookSkipMtls"
+                      type="checkbox"
+                      className="mt-1"
+                      checked={newWebhook.skipMtls}
+                      onChange={(e) => setNewWebhook(prev => ({ ...prev, skipMtls: e.target.checked, hmac: e.target.checked }))}
+                    />
+                    <Label htmlFor="webhookSkipMtls" className="text-sm font-normal">
+                      Pular verificação mTLS (x-skip-mtls-checking). Use apenas se o servidor que recebe as notificações não valida o certificado da EFI.
+                    </Label>
+                  </div>
+                  <div className="flex items-start gap-2">
+                    <input
+                      id="webhookHmac"
+                      type="checkbox"
+                      className="mt-1"
+                      checked={newWebhook.hmac}
+                      onChange={(e) => setNewWebhook(prev => ({ ...prev, hmac: e.target.checked }))}
+                    />
+                    <Label htmlFor="webhookHmac" className="text-sm font-normal">
+                      Autenticar notificações com um segredo gerado na URL (?hmac=). Recomendado ao pular a verificação mTLS.
+                    </Label>
+                  </div>
                 </div>
                 <DialogFooter>
                   <Button variant="outline" onClick={() => setIsConfiguring(false)}>
@@ -18,6 +18,29 @@ export interface ApiResponse<T> {
   status?: number
 }
 
+const SESSION_STORAGE_KEY = 'pix_session'
+
+// Token da sessão aberta em /api/auth/login, enviado como Authorization: Bearer
+export function getSessionToken(): string | null {
+  if (typeof window === 'undefined') return null
+  return window.sessionStorage.getItem(SESSION_STORAGE_KEY)
+}
+
+export function setSessionToken(token: string | null) {
+  if (typeof window === 'undefined') return
+  if (token) {
+    window.sessionStorage.setItem(SESSION_STORAGE_KEY, token)
+  } else {
+    window.sessionStorage.removeItem(SESSION_STORAGE_KEY)
+  }
+}
+
+// Cabeçalhos de autenticação para chamadas feitas fora do ApiClient
+export function authHeaders(): Record<string, string> {
+  const token = getSessionToken()
+  return token ? { Authorization: `Bearer ${token}` } : {}
+}
+
 class ApiClient {
   private baseUrl: string
 
@@ -31,11 +54,13 @@ class ApiClient {
   ): Promise<ApiResponse<T>> {
     const url = `${this.baseUrl}${endpoint}`
     const response = await fetch(url, {
+      ...options,
+      credentials: 'include',
       headers: {
         'Content-Type': 'application/json',
+        ...authHeaders(),
         ...options.headers,
       },
-      ...options,
     })
 
     let payload: any = undefined
@@ -56,15 +81,50 @@ class ApiClient {
     }
   }
 
+  // Login com usuário e senha locais; guarda o token da sessão
+  async login(username: string, password: string): Promise<ApiResponse<any>> {
+    const response = await this.request<any>('/api/auth/login', {
+      method: 'POST',
+      body: JSON.stringify({ username, password }),
+    })
+    if (response.success) {
+      setSessionToken(response.data?.data?.token || null)
+    }
+    return response
+  }
+
+  // Encerra a sessão atual
+  async logout(): Promise<ApiResponse<any>> {
+    const response = await this.request('/api/auth/logout', { method: 'POST' })
+    setSessionToken(null)
+    return response
+  }
+
+  // Usuário autenticado
+  async me(): Promise<ApiResponse<any>> {
+    return this.request('/api/auth/me')
+  }
+
   // Configurar webhook
-  async configWebhook(type: 'charge' | 'recurrence', url: string, env?: 'sandbox' | 'production'): Promise<ApiResponse<any>> {
-    const body = env ? { type, url, env } : { type, url }
+  // skipMtls envia x-skip-mtls-checking: a EFI não exige mTLS do receptor;
+  // hmac acrescenta à URL um segredo gerado, exigido pelo receptor
+  async configWebhook(type: 'charge' | 'recurrence', url: string, env?: 'sandbox' | 'production', options: { skipMtls?: boolean; hmac?: boolean } = {}): Promise<ApiResponse<any>> {
+    const body = env ? { type, url, env, ...options } : { type, url, ...options }
     return this.request('/api/webhook/config', {
       method: 'POST',
       body: JSON.stringify(body),
     })
   }
 
+  // Trocar o segredo (?hmac=) de um webhook
+  async rotateWebhookSecret(type: 'charge' | 'recurrence', env?: 'sandbox' | 'production'): Promise<ApiResponse<any>> {
+    const body = env ? { type, env } : { type }
+    return this.request('/api/webhook/rotate-secret', {
+      method: 'POST',
+      body: JSON.stringify(body),
+    })
+  }
+
   // Listar webhooks
   async listWebhooks(type: 'charge' | 'recurrence', env?: 'sandbox' | 'production'): Promise<ApiResponse<any>> {
     const url = env ? `/api/webhook/list?type=${type}&env=${env}` : `/api/webhook/list?type=${type}`